@@ -11,7 +11,10 @@ import (
 	"github.com/araddon/dateparse"
 
 	"github.com/mckern/spiry/internal/certificate"
+	"github.com/mckern/spiry/internal/crl"
 	"github.com/mckern/spiry/internal/domain"
+	"github.com/mckern/spiry/internal/file"
+	"github.com/mckern/spiry/internal/lint"
 	"github.com/mckern/spiry/internal/spiry"
 )
 
@@ -48,6 +51,8 @@ type cli struct {
 	spiry.Command
 	Domain      domain.Command      `cmd:"domain" help:"look up domain expiration date"`
 	Certificate certificate.Command `cmd:"certificate" help:"look up TLS certificate expiration date"`
+	File        file.Command        `cmd:"file" help:"look up expiration dates of certificates in a file"`
+	CRL         crl.Command         `cmd:"crl" help:"look up next update date of a certificate revocation list"`
 }
 
 func main() {
@@ -61,7 +66,11 @@ func main() {
 			Summary:             true,
 		}),
 		kong.UsageOnError(),
-		kong.Vars{"version": strings.TrimSpace(versionMsg())},
+		kong.Vars{
+			"version":   strings.TrimSpace(versionMsg()),
+			"lints":     strings.Join(lint.Names(), ","),
+			"lintNames": strings.Join(lint.Names(), ", "),
+		},
 	)
 
 	ctx, err := app.Parse(os.Args[1:])
@@ -0,0 +1,177 @@
+package certificate
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+// lookupIPAddr resolves a host name to its IP addresses
+var lookupIPAddr = net.DefaultResolver.LookupIPAddr
+
+// Backend is the outcome of retrieving a certificate chain from
+// one of the addresses that a server's name resolves to
+type Backend struct {
+	Address string
+	Chain   []*x509.Certificate
+	Err     error
+	// Differs is set when the leaf certificate is not the one
+	// served by most of the other addresses
+	Differs bool
+
+	state tls.ConnectionState
+}
+
+// Backends returns the outcome of retrieving a certificate chain from
+// every address the server's name resolves to, when AllAddresses is set
+func (c *Certificate) Backends() ([]Backend, error) {
+	if _, err := c.Expiry(); err != nil {
+		return nil, err
+	}
+
+	return c.backends, nil
+}
+
+// retrieveAll fetches a certificate chain from every address the
+// server's name resolves to. The chain with the earliest expiration
+// date is used as the certificate's chain, so that the worst of the
+// lot is what gets reported.
+func (c *Certificate) retrieveAll() error {
+	addrs, err := c.resolve()
+	if err != nil {
+		return err
+	}
+
+	backends := make([]Backend, len(addrs))
+	var wg sync.WaitGroup
+	for i, addr := range addrs {
+		wg.Go(func() {
+			state, err := c.getState(addr, c.tlsConfig())
+			backends[i] = Backend{Address: addr, Chain: state.PeerCertificates, Err: err, state: state}
+		})
+	}
+	wg.Wait()
+
+	var errs []error
+	var earliest time.Time
+	for _, b := range backends {
+		if b.Err != nil {
+			slog.Debug("unable to retrieve certificate", "address", b.Address, "error", b.Err)
+			errs = append(errs, fmt.Errorf("%v: %w", b.Address, b.Err))
+			continue
+		}
+
+		if expiry := c.chainExpiry(b.Chain); earliest.IsZero() || expiry.Before(earliest) {
+			earliest = expiry
+			c.setState(b.state)
+		}
+	}
+
+	if c.raw == nil {
+		return errors.Join(errs...)
+	}
+
+	markDiffering(backends)
+	c.backends = backends
+	return nil
+}
+
+// resolve returns every address the server's name resolves to, joined
+// with the server's port. Addresses in Network.Resolve are used instead
+// of looking the name up, and any outside of Network.Family are skipped.
+func (c *Certificate) resolve() ([]string, error) {
+	if overrides, err := c.Network.overrides(c.addr); overrides != nil || err != nil {
+		return overrides, err
+	}
+
+	host, port, err := net.SplitHostPort(c.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if net.ParseIP(host) != nil {
+		return []string{c.addr}, nil
+	}
+
+	ips, err := lookupIPAddr(context.Background(), host)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		if !c.Network.inFamily(ip.IP) {
+			continue
+		}
+		addrs = append(addrs, net.JoinHostPort(ip.String(), port))
+	}
+
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no %s addresses found for %v", c.Network.network(), host)
+	}
+
+	slog.Debug("resolved addresses", "host", host, "addresses", addrs)
+	return addrs, nil
+}
+
+// markDiffering flags every backend whose leaf certificate differs from
+// the one served by the most backends; ties go to the first one seen
+func markDiffering(backends []Backend) {
+	counts := map[[sha256.Size]byte]int{}
+	var common [sha256.Size]byte
+	for _, b := range backends {
+		if b.Err != nil {
+			continue
+		}
+
+		sum := sha256.Sum256(b.Chain[0].Raw)
+		counts[sum]++
+		if counts[sum] > counts[common] {
+			common = sum
+		}
+	}
+
+	for i, b := range backends {
+		backends[i].Differs = b.Err == nil && sha256.Sum256(b.Chain[0].Raw) != common
+	}
+}
+
+// reportBackends describes the certificate served by every address,
+// calling out the ones that differ
+func (c *Certificate) reportBackends(format func(time.Time) string) (backends []map[string]any, lines []string) {
+	for _, b := range c.backends {
+		if b.Err != nil {
+			backends = append(backends, map[string]any{
+				"address": b.Address,
+				"error":   b.Err.Error(),
+			})
+			lines = append(lines, fmt.Sprintf("  %s\terror=%q", b.Address, b.Err))
+			continue
+		}
+
+		d := DetailsOf(b.Chain[0])
+		expiry := format(c.chainExpiry(b.Chain))
+		backends = append(backends, map[string]any{
+			"address":           b.Address,
+			"expiry":            expiry,
+			"serialNumber":      d.SerialNumber,
+			"sha256Fingerprint": d.Fingerprint,
+			"differs":           b.Differs,
+		})
+
+		line := fmt.Sprintf("  %s\texpiry=%s\tsha256=%s", b.Address, expiry, d.Fingerprint)
+		if b.Differs {
+			line += "\tDIFFERS"
+		}
+		lines = append(lines, line)
+	}
+
+	return
+}
@@ -0,0 +1,60 @@
+package certificate_test
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"testing"
+
+	"github.com/likexian/gokit/assert"
+	"github.com/mckern/spiry/internal/certificate"
+)
+
+func TestAllAddresses(t *testing.T) {
+	// only Linux routes all of 127.0.0.0/8 to the loopback interface
+	for _, ip := range []string{"127.0.0.2", "127.0.0.3"} {
+		l, err := net.Listen("tcp", ip+":0")
+		if err != nil {
+			t.Skipf("unable to listen on %v: %v", ip, err)
+		}
+		_ = l.Close()
+	}
+
+	current, stale := newTestPKI(t), newTestPKI(t)
+	currentConfig := &tls.Config{Certificates: []tls.Certificate{current.tlsCertificate()}}
+
+	addr := serveTLSOn(t, "127.0.0.1:0", currentConfig)
+	_, port, _ := net.SplitHostPort(addr)
+	serveTLSOn(t, "127.0.0.2:"+port, currentConfig)
+	serveTLSOn(t, "127.0.0.3:"+port, &tls.Config{Certificates: []tls.Certificate{stale.tlsCertificate()}})
+
+	restore := certificate.SetLookupIPAddr(func(_ context.Context, host string) ([]net.IPAddr, error) {
+		assert.Equal(t, host, "backends.example.com", "the server's name should be resolved")
+		return []net.IPAddr{
+			{IP: net.IPv4(127, 0, 0, 1)},
+			{IP: net.IPv4(127, 0, 0, 2)},
+			{IP: net.IPv4(127, 0, 0, 3)},
+			// nothing listens here, so it should be reported as an error
+			{IP: net.IPv4(127, 0, 0, 4)},
+		}, nil
+	})
+	defer restore()
+
+	cert, err := certificate.New("backends.example.com:" + port)
+	assert.Nil(t, err, "a host:port pair should parse")
+	cert.AllAddresses = true
+
+	backends, err := cert.Backends()
+	assert.Nil(t, err, "certificates should be retrieved from every reachable address")
+	assert.Equal(t, len(backends), 4, "every resolved address should be reported")
+
+	assert.Nil(t, backends[0].Err, "the first address should be reachable")
+	assert.False(t, backends[0].Differs, "the most common certificate should not be called out")
+	assert.False(t, backends[1].Differs, "the most common certificate should not be called out")
+	assert.True(t, backends[2].Differs, "a stale certificate should be called out")
+	assert.NotNil(t, backends[3].Err, "an unreachable address should be reported")
+
+	reported, lines := certificate.ReportBackends(cert, rfc3339)
+	assert.Equal(t, len(reported), len(backends), "every address should be reported as a JSON field")
+	assert.Contains(t, lines[2], "DIFFERS", "a stale certificate should be called out in plain output")
+}
@@ -0,0 +1,214 @@
+package certificate
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// maxAIADepth limits how many issuers are fetched for one chain,
+// in case of an issuer loop
+const maxAIADepth = 5
+
+// Completion is the outcome of following AIA caIssuers URLs
+// to find intermediates missing from the chain a server presented
+type Completion struct {
+	// Complete is set unless intermediates were found to be missing;
+	// a chain that could not be followed to a root is listed in Errors
+	Complete bool
+	// Fetched is every intermediate the server should have presented,
+	// in chain order
+	Fetched []*x509.Certificate
+	// Errors explains why the chain could not be completed, if it could not
+	Errors []string
+}
+
+// CompleteChain reports whether the server presented every intermediate
+// certificate, and returns any that were fetched from AIA caIssuers URLs
+// to complete the chain, when ChaseAIA is set
+func (c *Certificate) CompleteChain() (*Completion, error) {
+	if _, err := c.Expiry(); err != nil {
+		return nil, err
+	}
+
+	return c.completion, nil
+}
+
+// fullChain returns the presented chain followed by
+// any intermediates that were fetched to complete it
+func (c *Certificate) fullChain() []*x509.Certificate {
+	if c.completion == nil || len(c.completion.Fetched) == 0 {
+		return c.chain
+	}
+
+	return append(append([]*x509.Certificate{}, c.chain...), c.completion.Fetched...)
+}
+
+// completeChain follows AIA caIssuers URLs from the last certificate
+// in chain until a root is reached. Roots are not expected to be
+// presented by the server, so they are not counted as missing.
+func (c *Certificate) completeChain(chain []*x509.Certificate) *Completion {
+	comp := &Completion{Complete: true}
+	last := chain[len(chain)-1]
+
+	for range maxAIADepth {
+		if isSelfSigned(last) || c.trusted(last) {
+			return comp
+		}
+
+		issuer, err := c.fetchIssuer(last)
+		if err != nil {
+			comp.Errors = append(comp.Errors, err.Error())
+			return comp
+		}
+
+		if isSelfSigned(issuer) {
+			return comp
+		}
+
+		slog.Debug("found missing intermediate", "subject", issuer.Subject)
+		comp.Complete = false
+		comp.Fetched = append(comp.Fetched, issuer)
+		last = issuer
+	}
+
+	comp.Errors = append(comp.Errors, fmt.Sprintf("gave up after fetching %d issuers", maxAIADepth))
+	return comp
+}
+
+// trusted reports whether cert was issued directly by one of Roots,
+// or by one of the system roots if Roots is nil
+func (c *Certificate) trusted(cert *x509.Certificate) bool {
+	roots := c.Roots
+	if roots == nil {
+		var err error
+		if roots, err = x509.SystemCertPool(); err != nil {
+			return false
+		}
+	}
+
+	// only the issuer matters here, so the certificate is checked
+	// at a time it is valid, rather than now
+	_, err := cert.Verify(x509.VerifyOptions{
+		Roots:       roots,
+		CurrentTime: cert.NotAfter.Add(-time.Second),
+		KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return err == nil
+}
+
+// fetchIssuer retrieves the certificate that issued cert
+// from the first of its AIA caIssuers URLs that has it
+func (c *Certificate) fetchIssuer(cert *x509.Certificate) (*x509.Certificate, error) {
+	if len(cert.IssuingCertificateURL) == 0 {
+		return nil, fmt.Errorf("no AIA caIssuers URL to find the issuer of %q", cert.Subject)
+	}
+
+	var errs []error
+	for _, url := range cert.IssuingCertificateURL {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		data, err := c.Network.Fetch(req)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		candidates, err := parseIssuers(data)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", url, err))
+			continue
+		}
+
+		for _, candidate := range candidates {
+			if cert.CheckSignatureFrom(candidate) == nil {
+				return candidate, nil
+			}
+		}
+
+		errs = append(errs, fmt.Errorf("%s: no certificate found that issued %q", url, cert.Subject))
+	}
+
+	return nil, errors.Join(errs...)
+}
+
+// isSelfSigned reports whether cert is a self-signed root
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignatureFrom(cert) == nil
+}
+
+// pkcs7 is just enough of a PKCS#7 ContentInfo wrapping SignedData,
+// per RFC 2315, to read the certificates out of a certs-only bundle
+type pkcs7 struct {
+	ContentType asn1.ObjectIdentifier
+	SignedData  struct {
+		Version          int
+		DigestAlgorithms asn1.RawValue
+		ContentInfo      asn1.RawValue
+		Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	} `asn1:"explicit,tag:0"`
+}
+
+// parseIssuers returns the certificates in data, which CAs publish
+// as DER, PEM or a certs-only PKCS#7 bundle
+func parseIssuers(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type == "CERTIFICATE" {
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			certs = append(certs, cert)
+		}
+	}
+
+	if len(certs) > 0 {
+		return certs, nil
+	}
+
+	if certs, err := x509.ParseCertificates(data); err == nil {
+		return certs, nil
+	}
+
+	var p pkcs7
+	if _, err := asn1.Unmarshal(data, &p); err != nil {
+		return nil, errors.New("data is not a DER, PEM or PKCS#7 certificate")
+	}
+
+	return x509.ParseCertificates(p.SignedData.Certificates.Bytes)
+}
+
+// report returns comp as JSON fields and lines of plain output,
+// using format for any times
+func (comp *Completion) report(format func(time.Time) string) (fields map[string]any, lines []string) {
+	fetched, fetchedLines := DescribeChain(comp.Fetched, format)
+	fields = map[string]any{
+		"complete": comp.Complete,
+		"fetched":  fetched,
+		"errors":   nonNil(comp.Errors),
+	}
+
+	if comp.Complete {
+		lines = append(lines, "  chain complete")
+	} else {
+		lines = append(lines, fmt.Sprintf("  chain incomplete: %d intermediates missing, fetched from AIA", len(comp.Fetched)))
+		lines = append(lines, fetchedLines...)
+	}
+
+	for _, err := range comp.Errors {
+		lines = append(lines, "  unable to complete chain: "+err)
+	}
+
+	return
+}
@@ -0,0 +1,174 @@
+package certificate_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/likexian/gokit/assert"
+	"github.com/mckern/spiry/internal/certificate"
+)
+
+// newAIAPKI builds a root, intermediate and leaf whose AIA caIssuers
+// URLs point at a local server publishing the root as DER and the
+// intermediate as a certs-only PKCS#7 bundle
+func newAIAPKI(t *testing.T) *testPKI {
+	t.Helper()
+
+	published := map[string][]byte{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := published[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+
+	now := time.Now().Truncate(time.Second)
+	pki := &testPKI{}
+
+	pki.root = newTestCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "spiry test root"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil)
+
+	pki.intermediate = newTestCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "spiry test intermediate"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(0, 0, 30),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IssuingCertificateURL: []string{server.URL + "/root.crt"},
+	}, &pki.root)
+
+	pki.leaf = newTestCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(0, 0, 90),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IssuingCertificateURL: []string{server.URL + "/missing.crt", server.URL + "/intermediate.p7c"},
+	}, &pki.intermediate)
+
+	published["/root.crt"] = pki.root.cert.Raw
+	published["/intermediate.p7c"] = certsOnlyPKCS7(t, pki.intermediate.cert)
+
+	return pki
+}
+
+// certsOnlyPKCS7 wraps certs in a degenerate PKCS#7 SignedData structure
+func certsOnlyPKCS7(t *testing.T, certs ...*x509.Certificate) []byte {
+	t.Helper()
+
+	var raw []byte
+	for _, cert := range certs {
+		raw = append(raw, cert.Raw...)
+	}
+
+	type contentInfo struct {
+		ContentType asn1.ObjectIdentifier
+	}
+
+	signedData, err := asn1.Marshal(struct {
+		Version          int
+		DigestAlgorithms asn1.RawValue
+		ContentInfo      contentInfo
+		Certificates     asn1.RawValue
+	}{
+		Version:          1,
+		DigestAlgorithms: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true},
+		ContentInfo:      contentInfo{ContentType: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: raw},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := asn1.Marshal(struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue
+	}{
+		ContentType: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2},
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signedData},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func TestChaseAIA(t *testing.T) {
+	pki := newAIAPKI(t)
+
+	// only the leaf is presented
+	addr := serveTLS(t, &tls.Config{Certificates: []tls.Certificate{{
+		Certificate: [][]byte{pki.leaf.cert.Raw},
+		PrivateKey:  pki.leaf.key,
+	}}})
+
+	cert, err := certificate.New(addr)
+	assert.Nil(t, err, "a host:port pair should parse")
+	cert.Chain = true
+	cert.ChaseAIA = true
+
+	expiry, err := cert.Expiry()
+	assert.Nil(t, err, "the certificate should be retrieved")
+	assert.Equal(t, expiry, pki.intermediate.cert.NotAfter, "the fetched intermediate's expiry should be used")
+
+	completion, err := cert.CompleteChain()
+	assert.Nil(t, err, "the certificate should be retrieved")
+	assert.False(t, completion.Complete, "a missing intermediate should be reported")
+	assert.Equal(t, len(completion.Fetched), 1, "the missing intermediate should be fetched")
+	assert.Equal(t, completion.Fetched[0].Subject.CommonName, "spiry test intermediate")
+	assert.Equal(t, len(completion.Errors), 0, "the chain should be completed")
+
+	fields, lines := certificate.ReportOf(completion, rfc3339)
+	assert.Equal(t, fields["complete"], false, "the chain's completeness should be reported as a JSON field")
+	assert.Contains(t, lines[0], "chain incomplete", "a missing intermediate should be called out in plain output")
+}
+
+func TestChaseAIAComplete(t *testing.T) {
+	pki := newAIAPKI(t)
+	addr := serveTLS(t, &tls.Config{Certificates: []tls.Certificate{pki.tlsCertificate()}})
+
+	cert, err := certificate.New(addr)
+	assert.Nil(t, err, "a host:port pair should parse")
+	cert.ChaseAIA = true
+
+	completion, err := cert.CompleteChain()
+	assert.Nil(t, err, "the certificate should be retrieved")
+	assert.True(t, completion.Complete, "a complete chain should be reported")
+	assert.Equal(t, len(completion.Fetched), 0, "nothing should be fetched for a complete chain")
+}
+
+func TestChaseAIAWithoutURL(t *testing.T) {
+	pki := newTestPKI(t)
+	addr := serveTLS(t, &tls.Config{Certificates: []tls.Certificate{{
+		Certificate: [][]byte{pki.leaf.cert.Raw},
+		PrivateKey:  pki.leaf.key,
+	}}})
+
+	cert, err := certificate.New(addr)
+	assert.Nil(t, err, "a host:port pair should parse")
+	cert.ChaseAIA = true
+
+	completion, err := cert.CompleteChain()
+	assert.Nil(t, err, "the certificate should be retrieved")
+	assert.Equal(t, len(completion.Errors), 1, "a missing caIssuers URL should be reported")
+}
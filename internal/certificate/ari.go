@@ -0,0 +1,139 @@
+package certificate
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RenewalInfo is a CA's suggested renewal window for the
+// leaf certificate, from ACME Renewal Information (RFC 9773)
+type RenewalInfo struct {
+	CertID string
+	// WindowStart and WindowEnd bound the suggested renewal window,
+	// and Due is set once the window has opened
+	WindowStart time.Time
+	WindowEnd   time.Time
+	Due         bool
+	// ExplanationURL points at the CA's reason for the window, if any,
+	// such as an announcement of a mass revocation
+	ExplanationURL string
+}
+
+// acmeDirectory is the part of an ACME directory that ARI needs
+type acmeDirectory struct {
+	RenewalInfo string `json:"renewalInfo"`
+}
+
+// renewalInfoResponse is a renewalInfo resource, per RFC 9773, section 4.2
+type renewalInfoResponse struct {
+	SuggestedWindow struct {
+		Start time.Time `json:"start"`
+		End   time.Time `json:"end"`
+	} `json:"suggestedWindow"`
+	ExplanationURL string `json:"explanationURL"`
+}
+
+// RenewalInfo asks the renewalInfo endpoint of the ACME directory at
+// ACMEDirectory for the suggested renewal window of the leaf certificate
+func (c *Certificate) RenewalInfo() (*RenewalInfo, error) {
+	if c.renewalInfo != nil {
+		return c.renewalInfo, nil
+	}
+
+	if _, err := c.Expiry(); err != nil {
+		return nil, err
+	}
+
+	if c.ACMEDirectory == "" {
+		return nil, errors.New("no ACME directory URL given")
+	}
+
+	info, err := c.fetchRenewalInfo(c.ACMEDirectory, c.raw)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve renewal information from %v: %w", c.ACMEDirectory, err)
+	}
+
+	c.renewalInfo = info
+	return c.renewalInfo, nil
+}
+
+func (c *Certificate) fetchRenewalInfo(directoryURL string, leaf *x509.Certificate) (*RenewalInfo, error) {
+	certID, err := ARICertID(leaf)
+	if err != nil {
+		return nil, err
+	}
+
+	var directory acmeDirectory
+	if err := c.getJSON(directoryURL, &directory); err != nil {
+		return nil, err
+	}
+
+	if directory.RenewalInfo == "" {
+		return nil, errors.New("the ACME directory has no renewalInfo endpoint")
+	}
+
+	var resp renewalInfoResponse
+	if err := c.getJSON(strings.TrimSuffix(directory.RenewalInfo, "/")+"/"+certID, &resp); err != nil {
+		return nil, err
+	}
+
+	window := resp.SuggestedWindow
+	if window.Start.IsZero() || window.End.Before(window.Start) {
+		return nil, errors.New("the suggested renewal window is invalid")
+	}
+
+	return &RenewalInfo{
+		CertID:         certID,
+		WindowStart:    window.Start,
+		WindowEnd:      window.End,
+		Due:            !time.Now().Before(window.Start),
+		ExplanationURL: resp.ExplanationURL,
+	}, nil
+}
+
+// ARICertID returns the ARI certificate identifier of cert: its authority
+// key identifier and serial number, each base64url-encoded and joined
+// with a period, per RFC 9773, section 4.1
+func ARICertID(cert *x509.Certificate) (string, error) {
+	if len(cert.AuthorityKeyId) == 0 {
+		return "", errors.New("the certificate has no authority key identifier")
+	}
+
+	// the serial is encoded as its DER INTEGER contents, which
+	// need a leading zero byte when the high bit is set
+	serial := cert.SerialNumber.Bytes()
+	if len(serial) == 0 || serial[0]&0x80 != 0 {
+		serial = append([]byte{0}, serial...)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(cert.AuthorityKeyId) + "." +
+		base64.RawURLEncoding.EncodeToString(serial), nil
+}
+
+// report returns info as JSON fields and lines of plain output,
+// using format for any times
+func (info *RenewalInfo) report(format func(time.Time) string) (fields map[string]any, lines []string) {
+	fields = map[string]any{
+		"certID":         info.CertID,
+		"windowStart":    format(info.WindowStart),
+		"windowEnd":      format(info.WindowEnd),
+		"due":            info.Due,
+		"explanationURL": info.ExplanationURL,
+	}
+
+	line := fmt.Sprintf("  ARI suggested renewal window: %s to %s", format(info.WindowStart), format(info.WindowEnd))
+	if info.Due {
+		line += ", DUE"
+	}
+	lines = append(lines, line)
+
+	if info.ExplanationURL != "" {
+		lines = append(lines, "  ARI explanation: "+info.ExplanationURL)
+	}
+
+	return
+}
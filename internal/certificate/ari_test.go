@@ -0,0 +1,102 @@
+package certificate_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/likexian/gokit/assert"
+	"github.com/mckern/spiry/internal/certificate"
+)
+
+func TestARICertID(t *testing.T) {
+	// the example from RFC 9773, section 4.1
+	cert := &x509.Certificate{
+		AuthorityKeyId: []byte{
+			0x69, 0x88, 0x5B, 0x6B, 0x87, 0x46, 0x40, 0x41, 0xE1, 0xB3,
+			0x7B, 0x84, 0x7B, 0xA0, 0xAE, 0x2C, 0xDE, 0x01, 0xC8, 0xD4,
+		},
+		SerialNumber: big.NewInt(0x87654321),
+	}
+
+	id, err := certificate.ARICertID(cert)
+	assert.Nil(t, err, "a certificate with an authority key identifier should have an ID")
+	assert.Equal(t, id, "aYhba4dGQEHhs3uEe6CuLN4ByNQ.AIdlQyE")
+
+	_, err = certificate.ARICertID(&x509.Certificate{SerialNumber: big.NewInt(1)})
+	assert.NotNil(t, err, "a certificate without an authority key identifier should have no ID")
+}
+
+// serveACME serves an ACME directory with a renewalInfo endpoint
+// that suggests window for the certificate identified by certID
+func serveACME(t *testing.T, certID string, start time.Time, end time.Time) string {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	mux.HandleFunc("GET /directory", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"newNonce":    server.URL + "/new-nonce",
+			"renewalInfo": server.URL + "/renewal-info",
+		})
+	})
+	mux.HandleFunc("GET /renewal-info/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") != certID {
+			http.NotFound(w, r)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"suggestedWindow": map[string]any{"start": start, "end": end},
+			"explanationURL":  "https://acme.example.com/incident",
+		})
+	})
+
+	return server.URL + "/directory"
+}
+
+func TestRenewalInfo(t *testing.T) {
+	pki := newTestPKI(t)
+	certID, err := certificate.ARICertID(pki.leaf.cert)
+	assert.Nil(t, err, "the test leaf should have an ARI certificate ID")
+
+	now := time.Now().UTC().Truncate(time.Second)
+	start, end := now.Add(-time.Hour), now.Add(time.Hour)
+	directory := serveACME(t, certID, start, end)
+
+	cert, err := certificate.New(serveTLS(t, &tls.Config{Certificates: []tls.Certificate{pki.tlsCertificate()}}))
+	assert.Nil(t, err, "a host:port pair should parse")
+	cert.ACMEDirectory = directory
+
+	info, err := cert.RenewalInfo()
+	assert.Nil(t, err, "the renewal information should be retrieved")
+	assert.Equal(t, info.CertID, certID)
+	assert.Equal(t, info.WindowStart, start)
+	assert.Equal(t, info.WindowEnd, end)
+	assert.True(t, info.Due, "an open renewal window should be due")
+	assert.Equal(t, info.ExplanationURL, "https://acme.example.com/incident")
+
+	fields, lines := certificate.ReportOf(info, rfc3339)
+	assert.Equal(t, fields["due"], true, "the renewal window should be reported as a JSON field")
+	assert.Contains(t, lines[0], "DUE", "an open renewal window should be called out in plain output")
+}
+
+func TestRenewalInfoUnknown(t *testing.T) {
+	pki := newTestPKI(t)
+	now := time.Now()
+	directory := serveACME(t, "not-this-certificate", now, now.Add(time.Hour))
+
+	cert, err := certificate.New(serveTLS(t, &tls.Config{Certificates: []tls.Certificate{pki.tlsCertificate()}}))
+	assert.Nil(t, err, "a host:port pair should parse")
+	cert.ACMEDirectory = directory
+
+	_, err = cert.RenewalInfo()
+	assert.NotNil(t, err, "a certificate unknown to the CA should be reported")
+}
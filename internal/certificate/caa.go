@@ -0,0 +1,300 @@
+package certificate
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// caaFlagCritical marks a CAA property that a CA must understand
+// before it may issue, per RFC 8659, section 4.1
+const caaFlagCritical = 128
+
+// caaTags are the CAA property tags that have been defined, any of
+// which a CA can be expected to understand
+var caaTags = []string{"issue", "issuewild", "iodef", "issuemail", "issuevmc", "contactemail", "contactphone"}
+
+// caaIssuers maps the issuer domain names that CAs publish for use in
+// CAA records to words found in the names of their issuing certificates,
+// for CAs whose issuer domain doesn't give them away
+var caaIssuers = map[string][]string{
+	"amazon.com":        {"amazon"},
+	"amazonaws.com":     {"amazon"},
+	"amazontrust.com":   {"amazon"},
+	"awstrust.com":      {"amazon"},
+	"comodoca.com":      {"sectigo", "comodo", "usertrust", "zerossl"},
+	"digicert.com":      {"digicert", "geotrust", "rapidssl", "thawte", "encryptioneverywhere"},
+	"godaddy.com":       {"godaddy", "starfield"},
+	"pki.goog":          {"google"},
+	"sectigo.com":       {"sectigo", "comodo", "usertrust", "zerossl"},
+	"ssl.com":           {"sslcom", "sslcorp"},
+	"starfieldtech.com": {"starfield"},
+}
+
+// CAA is the outcome of checking whether the CAA records of the leaf
+// certificate's names permit the CA that issued it to issue its renewal
+type CAA struct {
+	// Issuer is the name of the CA that issued the leaf certificate
+	Issuer string
+	Names  []CAAName
+	// Permitted is set when the records of every name permit the issuer
+	Permitted bool
+}
+
+// CAAName is the relevant CAA record set of one of the leaf
+// certificate's names, found per RFC 8659, section 3
+type CAAName struct {
+	Name string
+	// Domain is where the relevant record set was found; it is empty
+	// when neither the name nor any of its parents have CAA records
+	Domain  string
+	Records []string
+	// Issuers are the issuer domains that the records permit to issue
+	// for the name, and Permitted is set when the CA is one of them.
+	// Any CA is permitted when the records don't restrict issuance.
+	Issuers   []string
+	Permitted bool
+	// Reason explains why the CA is not permitted
+	Reason string
+	Err    error
+}
+
+// CAA looks up the relevant CAA record set of every DNS name of the leaf
+// certificate, and checks whether each of them permits the certificate's
+// issuer to issue a renewal
+func (c *Certificate) CAA() (*CAA, error) {
+	if c.caa != nil {
+		return c.caa, nil
+	}
+
+	if _, err := c.Expiry(); err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, name := range c.raw.DNSNames {
+		if name = strings.ToLower(name); !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 {
+		return nil, errors.New("the certificate has no DNS names to look up CAA records for")
+	}
+
+	caa := &CAA{Issuer: issuerName(c.raw), Permitted: true}
+	sets := map[string][]*dns.CAA{}
+	for _, name := range names {
+		n := c.checkCAA(name, sets)
+		caa.Permitted = caa.Permitted && n.Err == nil && n.Permitted
+		caa.Names = append(caa.Names, n)
+	}
+
+	c.caa = caa
+	return c.caa, nil
+}
+
+// checkCAA finds the relevant CAA record set of name, climbing the DNS
+// tree towards the top-level domain until a domain with CAA records is
+// found, and checks whether it permits the leaf certificate's issuer.
+// Record sets already looked up are kept in sets.
+func (c *Certificate) checkCAA(name string, sets map[string][]*dns.CAA) CAAName {
+	n := CAAName{Name: name, Permitted: true}
+	wildcard := strings.HasPrefix(name, "*.")
+
+	labels := dns.SplitDomainName(strings.TrimPrefix(name, "*."))
+	for i := range labels {
+		domain := strings.Join(labels[i:], ".")
+		records, ok := sets[domain]
+		if !ok {
+			resp, err := c.lookup(domain, dns.TypeCAA)
+			if err != nil {
+				n.Err = fmt.Errorf("unable to look up CAA records for %v: %w", domain, err)
+				return n
+			}
+
+			for _, rr := range resp.Answer {
+				if record, ok := rr.(*dns.CAA); ok {
+					records = append(records, record)
+				}
+			}
+			sets[domain] = records
+		}
+
+		if len(records) > 0 {
+			n.Domain = domain
+			for _, r := range records {
+				n.Records = append(n.Records, fmt.Sprintf("%d %s %q", r.Flag, r.Tag, r.Value))
+			}
+			n.Issuers, n.Permitted, n.Reason = permitsIssuer(records, wildcard, c.raw)
+			break
+		}
+	}
+
+	return n
+}
+
+// permitsIssuer returns the issuer domains that records permit to issue
+// a certificate, using issuewild properties in place of issue properties
+// for wildcard names when there are any, and whether cert's issuer is
+// one of them
+func permitsIssuer(records []*dns.CAA, wildcard bool, cert *x509.Certificate) (issuers []string, permitted bool, reason string) {
+	tag := "issue"
+	for _, r := range records {
+		if r.Flag&caaFlagCritical != 0 && !slices.Contains(caaTags, strings.ToLower(r.Tag)) {
+			return nil, false, fmt.Sprintf("the critical %q property is not understood by any CA", r.Tag)
+		}
+		if wildcard && strings.EqualFold(r.Tag, "issuewild") {
+			tag = "issuewild"
+		}
+	}
+
+	restricted := false
+	for _, r := range records {
+		if !strings.EqualFold(r.Tag, tag) {
+			continue
+		}
+
+		restricted = true
+		domain, _, _ := strings.Cut(r.Value, ";")
+		if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" && !slices.Contains(issuers, domain) {
+			issuers = append(issuers, domain)
+		}
+	}
+
+	if !restricted {
+		return nil, true, ""
+	}
+
+	for _, domain := range issuers {
+		if isIssuerDomain(domain, cert) {
+			return issuers, true, ""
+		}
+	}
+
+	if len(issuers) == 0 {
+		return issuers, false, fmt.Sprintf("%s properties permit no CA", tag)
+	}
+
+	return issuers, false, fmt.Sprintf("%s properties permit only %s", tag, strings.Join(issuers, ", "))
+}
+
+// isIssuerDomain reports whether domain, an issuer domain from a CAA
+// record, belongs to the CA that issued cert. CAs are recognized by the
+// words in caaIssuers, or else by the first label of the issuer domain,
+// appearing in the issuer's organization or common name.
+func isIssuerDomain(domain string, cert *x509.Certificate) bool {
+	issuer := squash(strings.Join(append(cert.Issuer.Organization, cert.Issuer.CommonName), " "))
+
+	words, ok := caaIssuers[domain]
+	if !ok {
+		label, _, _ := strings.Cut(domain, ".")
+		if label = squash(label); len(label) < 4 {
+			return false
+		}
+		words = []string{label}
+	}
+
+	for _, word := range words {
+		if strings.Contains(issuer, word) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// squash lowercases s and strips everything but letters and digits
+// from it, so that e.g. "Let's Encrypt" and "letsencrypt" compare equal
+func squash(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		default:
+			return -1
+		}
+	}, s)
+}
+
+// issuerName returns the organization that issued cert,
+// or the issuer's common name if it has none
+func issuerName(cert *x509.Certificate) string {
+	if len(cert.Issuer.Organization) > 0 {
+		return cert.Issuer.Organization[0]
+	}
+
+	return cert.Issuer.CommonName
+}
+
+// report returns a as JSON fields and lines of plain output
+func (a *CAA) report(_ func(time.Time) string) (fields map[string]any, lines []string) {
+	names := make([]map[string]any, 0, len(a.Names))
+	var refused, failed []string
+	for _, n := range a.Names {
+		name := map[string]any{"name": n.Name}
+		if n.Err != nil {
+			name["error"] = n.Err.Error()
+			names = append(names, name)
+			failed = append(failed, n.Name)
+			continue
+		}
+
+		name["domain"] = n.Domain
+		name["records"] = n.Records
+		name["issuers"] = n.Issuers
+		name["permitted"] = n.Permitted
+		if !n.Permitted {
+			name["reason"] = n.Reason
+			refused = append(refused, n.Name)
+		}
+		names = append(names, name)
+	}
+
+	fields = map[string]any{
+		"issuer":    a.Issuer,
+		"names":     names,
+		"permitted": a.Permitted,
+	}
+
+	status := "permitted"
+	switch {
+	case len(refused) > 0:
+		status = fmt.Sprintf("WARNING, renewal would be refused for %s", strings.Join(refused, ", "))
+	case len(failed) > 0:
+		status = fmt.Sprintf("unable to check %s", strings.Join(failed, ", "))
+	}
+	lines = append(lines, fmt.Sprintf("  CAA for %s: %s", a.Issuer, status))
+
+	for _, n := range a.Names {
+		switch {
+		case n.Err != nil:
+			lines = append(lines, fmt.Sprintf("  CAA %s\terror=%q", n.Name, n.Err))
+		case n.Domain == "":
+			lines = append(lines, fmt.Sprintf("  CAA %s\tno records, any CA may issue", n.Name))
+		case n.Permitted:
+			lines = append(lines, fmt.Sprintf("  CAA %s\t%s permits %s", n.Name, n.Domain, permittedIssuers(n.Issuers)))
+		default:
+			lines = append(lines, fmt.Sprintf("  CAA %s\t%s REFUSES, %s", n.Name, n.Domain, n.Reason))
+		}
+	}
+
+	return
+}
+
+// permittedIssuers describes the issuer domains permitted by a CAA
+// record set that permits the CA in question
+func permittedIssuers(issuers []string) string {
+	if len(issuers) == 0 {
+		return "any CA"
+	}
+
+	return strings.Join(issuers, ", ")
+}
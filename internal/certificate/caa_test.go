@@ -0,0 +1,123 @@
+package certificate_test
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/likexian/gokit/assert"
+	"github.com/mckern/spiry/internal/certificate"
+	"github.com/miekg/dns"
+)
+
+// newCAA returns a CAA record at name
+func newCAA(name string, flag uint8, tag, value string) *dns.CAA {
+	return &dns.CAA{
+		Hdr:   dns.RR_Header{Name: dns.Fqdn(name), Rrtype: dns.TypeCAA, Class: dns.ClassINET, Ttl: 300},
+		Flag:  flag,
+		Tag:   tag,
+		Value: value,
+	}
+}
+
+func TestCAA(t *testing.T) {
+	pki := newTestPKI(t)
+	now := time.Now().Truncate(time.Second)
+	leaf := newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "www.example.com"},
+		DNSNames:    []string{"www.example.com", "*.example.com", "WWW.example.com"},
+		NotBefore:   now.Add(-time.Hour),
+		NotAfter:    now.AddDate(0, 0, 90),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, &pki.intermediate)
+	addr := serveLeaf(t, pki, leaf)
+
+	// the test intermediate is "spiry test intermediate",
+	// which is recognized as the CA for spiry.test
+	tests := []struct {
+		name      string
+		records   []dns.RR
+		domains   []string
+		permitted []bool
+		reason    string
+	}{
+		{
+			name:      "no records",
+			domains:   []string{"", ""},
+			permitted: []bool{true, true},
+		},
+		{
+			name:      "issuer permitted by a parent",
+			records:   []dns.RR{newCAA("example.com", 0, "issue", "spiry.test; accounturi=https://spiry.test/acct/1")},
+			domains:   []string{"example.com", "example.com"},
+			permitted: []bool{true, true},
+		},
+		{
+			name: "wildcards refused",
+			records: []dns.RR{
+				newCAA("example.com", 0, "issue", "spiry.test"),
+				newCAA("example.com", 0, "issuewild", "letsencrypt.org"),
+			},
+			domains:   []string{"example.com", "example.com"},
+			permitted: []bool{true, false},
+			reason:    "issuewild properties permit only letsencrypt.org",
+		},
+		{
+			name: "no CA permitted for a child",
+			records: []dns.RR{
+				newCAA("www.example.com", 0, "issue", ";"),
+				newCAA("example.com", 0, "issue", "spiry.test"),
+			},
+			domains:   []string{"www.example.com", "example.com"},
+			permitted: []bool{false, true},
+			reason:    "issue properties permit no CA",
+		},
+		{
+			name:      "another CA",
+			records:   []dns.RR{newCAA("example.com", 0, "issue", "pki.goog"), newCAA("example.com", 0, "iodef", "mailto:caa@example.com")},
+			domains:   []string{"example.com", "example.com"},
+			permitted: []bool{false, false},
+			reason:    "issue properties permit only pki.goog",
+		},
+		{
+			name:      "unknown critical property",
+			records:   []dns.RR{newCAA("example.com", 128, "tbs", "unknown"), newCAA("example.com", 0, "issue", "spiry.test")},
+			domains:   []string{"example.com", "example.com"},
+			permitted: []bool{false, false},
+			reason:    `the critical "tbs" property is not understood by any CA`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert, err := certificate.New(addr)
+			assert.Nil(t, err, "the address should parse")
+			cert.CheckCAA = true
+			cert.Resolver = serveDNS(t, tt.records...)
+
+			caa, err := cert.CAA()
+			assert.Nil(t, err, "the CAA records should be looked up")
+			assert.Equal(t, caa.Issuer, "spiry test intermediate")
+			assert.Equal(t, len(caa.Names), 2)
+
+			permitted := true
+			for i, n := range caa.Names {
+				assert.Nil(t, n.Err, "the CAA records should be looked up")
+				assert.Equal(t, n.Domain, tt.domains[i])
+				assert.Equal(t, n.Permitted, tt.permitted[i])
+				if !n.Permitted {
+					assert.Equal(t, n.Reason, tt.reason)
+				}
+				permitted = permitted && n.Permitted
+			}
+			assert.Equal(t, caa.Permitted, permitted)
+
+			fields, lines := certificate.ReportOf(caa, rfc3339)
+			assert.Equal(t, fields["permitted"], permitted, "the CAA audit should be reported as a JSON field")
+			assert.Equal(t, strings.Contains(lines[0], "WARNING"), !permitted, "a refusal should be called out in plain output")
+		})
+	}
+}
@@ -3,33 +3,201 @@ package certificate
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/asaskevich/govalidator"
+	"github.com/mckern/spiry/internal/lint"
 	"github.com/mckern/spiry/internal/spiry"
 )
 
 const defaultTLSPort = "443"
 
 type Certificate struct {
-	addr string
-	name string
-	raw  *x509.Certificate
+	// Chain considers every certificate presented by the server,
+	// instead of only the leaf certificate.
+	Chain bool
+	// StartTLS names the plaintext protocol used to negotiate TLS,
+	// e.g. "smtp"; the certificate is retrieved by dialing TLS directly
+	// when it is empty.
+	StartTLS string
+	// QUIC retrieves the certificate with a QUIC handshake
+	// over UDP, instead of a TLS handshake over TCP.
+	QUIC bool
+	// CompareQUIC retrieves the certificate over both TCP and QUIC,
+	// using the earlier expiration date and reporting any difference.
+	CompareQUIC bool
+	// KeyTypes asks the server for an RSA and an ECDSA certificate in
+	// separate handshakes, using the earliest expiration date of both.
+	KeyTypes bool
+	// TLSVersion pins the TLS version used in handshakes, e.g.
+	// tls.VersionTLS12; any supported version is used if it is zero.
+	TLSVersion uint16
+	// CheckRevocation asks the certificate's OCSP responders or
+	// CRL distribution points whether it has been revoked.
+	CheckRevocation bool
+	// StapleExpiry uses the NextUpdate date of a stapled OCSP response
+	// as the expiration date, when it comes before the certificate's.
+	StapleExpiry bool
+	// ALPN is the application protocol offered during the handshake;
+	// QUIC handshakes offer HTTP/3 ("h3") if it is empty.
+	ALPN string
+	// ChaseAIA follows AIA caIssuers URLs to fetch any intermediates
+	// the server failed to present, including them in the chain's
+	// expiration date.
+	ChaseAIA bool
+	// CheckName reports whether the leaf certificate covers
+	// the requested name.
+	CheckName bool
+	// ListSANs reports every subject alternative name of the leaf
+	// certificate, and CheckSANs retrieves and checks the certificate
+	// served for each of them as well.
+	ListSANs  bool
+	CheckSANs bool
+	// RenewalWindow reports the leaf certificate's renewal window. The
+	// leaf is due for renewal once RenewalThreshold of its lifetime has
+	// passed, or DefaultRenewalThreshold if it is zero.
+	RenewalWindow    bool
+	RenewalThreshold float64
+	// CheckDANE matches the certificate chain against the TLSA records
+	// of the server's name and port.
+	CheckDANE bool
+	// CheckCAA checks that the CAA records of the leaf certificate's
+	// names permit its issuer to issue a renewal.
+	CheckCAA bool
+	// Resolver is the host:port of the DNS resolver used for DNS
+	// checks; the first system resolver is used if it is empty.
+	Resolver string
+	// ACMEDirectory is the URL of an ACME directory whose renewalInfo
+	// endpoint is asked for the leaf certificate's suggested renewal
+	// window (RFC 9773).
+	ACMEDirectory string
+	// CTLog is the URL of an RFC 6962 Certificate Transparency log
+	// whose most recent CTEntries entries are searched for other
+	// certificates issued for the leaf certificate's names.
+	CTLog     string
+	CTEntries int
+	// StateDir is where the leaf certificate served by each server is
+	// recorded between checks, so that changes to it can be reported.
+	StateDir string
+	// Lint checks the leaf certificate with Lints, or every registered
+	// lint if Lints is nil, and reports the findings; lints named in
+	// SkipLints are not run.
+	Lint      bool
+	Lints     []lint.Lint
+	SkipLints []string
+	// AllAddresses retrieves a certificate from every address the server's
+	// name resolves to, using the earliest expiration date of them all.
+	AllAddresses bool
+	// Detailed includes the leaf certificate's details in plain
+	// output; they are always included in JSON output.
+	Detailed bool
+	// VerifyChain reports the outcome of Verify alongside
+	// the expiration date.
+	VerifyChain bool
+	// Network controls how connections to the server are made.
+	Network NetworkOptions
+	// ClientCertificate is presented to servers that ask for one.
+	ClientCertificate *tls.Certificate
+	// Roots are the trusted root certificates used by Verify;
+	// the system roots are used when it is nil.
+	Roots *x509.CertPool
+
+	addr  string
+	name  string
+	raw   *x509.Certificate
+	chain []*x509.Certificate
+
+	backends     []Backend
+	quicChain    []*x509.Certificate
+	quicErr      error
+	keyTypes     []KeyTypeCertificate
+	session      Session
+	verification *Verification
+	revocation   *Revocation
+	completion   *Completion
+	sans         []SAN
+	findings     []lint.Finding
+	transparency *Transparency
+	renewalInfo  *RenewalInfo
+	dane         *DANE
+	caa          *CAA
+	tracking     *Tracking
 }
 
-var _ spiry.ExpiringResource = (*Certificate)(nil)
+var (
+	_ spiry.ExpiringResource = (*Certificate)(nil)
+	_ spiry.Reporter         = (*Certificate)(nil)
+)
 
 type Command struct {
-	DomainName string `name:"name" short:"n" help:"request TLS certificate for domain <name> instead of <address>"`
-	Insecure   bool   `name:"insecure" short:"k" help:"allow insecure server connections"`
-	Addr       string `arg:"" name:"address" help:"address to retrieve TLS certificate from"`
+	DomainName  string   `name:"name" short:"n" help:"request TLS certificate for domain <name> instead of <address>"`
+	Insecure    bool     `name:"insecure" short:"k" xor:"verify,ca-file" help:"allow insecure server connections"`
+	Verify      bool     `name:"verify" short:"V" xor:"verify" help:"verify the certificate chain and name, failing if either is invalid"`
+	CAFile      string   `name:"ca-file" type:"existingfile" xor:"ca-file" placeholder:"PATH" help:"verify against the PEM certificates in <path> instead of the system roots; implies --verify"`
+	Chain       bool     `name:"chain" short:"c" help:"report every certificate in the chain and use the earliest expiration date"`
+	Details     bool     `name:"details" short:"d" help:"display the certificate's issuer, serial number, names, key and fingerprint"`
+	CheckName   bool     `name:"check-name" help:"check that the certificate covers the requested name, and exit non-zero if it doesn't"`
+	ListSANs    bool     `name:"sans" help:"list every subject alternative name of the certificate"`
+	CheckSANs   bool     `name:"check-sans" help:"list every subject alternative name, check the certificate served for each of them, and exit non-zero if any aren't valid for their name"`
+	Renewal     bool     `name:"renewal" help:"show how much of the certificate's lifetime has been consumed, and when it is due for renewal"`
+	RenewAt     float64  `name:"renew-at" placeholder:"PERCENT" help:"consider the certificate due for renewal once <percent> of its lifetime has passed, rather than two thirds of it"`
+	FailOverdue bool     `name:"fail-overdue" help:"exit non-zero if the certificate is overdue for renewal"`
+	DANE        bool     `name:"dane" help:"match the certificate chain against the TLSA records for <address>, and exit non-zero if none match or they are not DNSSEC authenticated"`
+	CAA         bool     `name:"caa" help:"warn if the CAA records of the certificate's names would refuse a renewal from its issuer"`
+	Resolver    string   `name:"resolver" placeholder:"HOST:PORT" help:"send DNS queries to the resolver at <host:port> instead of the system resolver"`
+	ARI         string   `name:"ari" placeholder:"URL" help:"ask the ACME directory at <url> for the certificate's suggested renewal window"`
+	CTLog       string   `name:"ct-log" placeholder:"URL" help:"search the Certificate Transparency log at <url> for other certificates issued for the certificate's names"`
+	CTEntries   int      `name:"ct-entries" default:"1000" placeholder:"COUNT" help:"search the most recent <count> entries of the --ct-log"`
+	Track       bool     `name:"track" help:"report whether the certificate has changed, been renewed or regressed since the last check"`
+	StateDir    string   `name:"state-dir" placeholder:"PATH" help:"record the certificates seen by --track in the directory <path>; implies --track"`
+	Lint        bool     `name:"lint" help:"check the certificate for problems besides its expiration date, and exit non-zero if any errors are found"`
+	SkipLints   []string `name:"skip-lint" enum:"${lints}" placeholder:"LINT" help:"don't run <lint>: ${lintNames}"`
+	ChaseAIA    bool     `name:"chase-aia" help:"fetch any intermediates the server fails to present from AIA caIssuers URLs"`
+	AllAddrs    bool     `name:"all-addresses" short:"A" help:"retrieve a certificate from every IPv4 and IPv6 address of <address> and report any differences"`
+	NetworkFlags
+	Retries        int           `name:"retries" default:"0" help:"retry failed connections <n> times"`
+	RetryBackoff   time.Duration `name:"retry-backoff" default:"500ms" help:"wait <duration> before retrying, doubling it with each retry"`
+	Resolve        []string      `name:"resolve" sep:"none" placeholder:"HOST:PORT:ADDR" help:"connect to <addr> instead of resolving <host>:<port>; may be repeated"`
+	ClientCert     string        `name:"client-cert" type:"existingfile" placeholder:"PATH" help:"present the PEM or PKCS#12 client certificate in <path>"`
+	ClientKey      string        `name:"client-key" type:"existingfile" placeholder:"PATH" help:"use the PEM private key in <path> for --client-cert"`
+	ClientPassword string        `name:"client-cert-password" env:"SPIRY_CLIENT_CERT_PASSWORD" placeholder:"PASSWORD" help:"decrypt a PKCS#12 --client-cert with <password>"`
+	QUIC           bool          `name:"quic" xor:"quic" help:"retrieve the certificate with a QUIC handshake over UDP"`
+	CompareQUIC    bool          `name:"compare-quic" xor:"quic" help:"retrieve the certificate over both TCP and QUIC, and report any difference"`
+	KeyTypes       bool          `name:"key-types" help:"ask for the server's RSA and ECDSA certificates separately, using TLS 1.2"`
+	TLSVersion     string        `name:"tls-version" enum:",1.0,1.1,1.2,1.3" default:"" placeholder:"VERSION" help:"only use TLS <version>: 1.0, 1.1, 1.2 or 1.3"`
+	Revocation     bool          `name:"check-revocation" help:"check whether the certificate has been revoked, using OCSP or CRLs"`
+	StapleExpiry   bool          `name:"staple-expiry" help:"use the next update date of a stapled OCSP response as the expiration date, if it is earlier"`
+	ALPN           string        `name:"alpn" placeholder:"PROTOCOL" help:"offer application protocol <protocol> during the handshake; QUIC defaults to h3"`
+	StartTLS       string        `name:"starttls" enum:",smtp,imap,pop3,sieve,postgres,postgresql,mysql,ldap,ftp,xmpp,xmpp-server" default:"" placeholder:"PROTOCOL" help:"negotiate TLS in-band for <protocol>: smtp, imap, pop3, sieve, postgres, mysql, ldap, ftp, xmpp or xmpp-server"`
+	Addr           string        `arg:"" name:"address" help:"address to retrieve TLS certificate from"`
 }
 
 func (c *Command) Run(globals *spiry.Command) (err error) {
+	if c.RenewAt < 0 || c.RenewAt > 100 {
+		return fmt.Errorf("--renew-at must be a percentage between 0 and 100, not %v", c.RenewAt)
+	}
+
+	if c.CTEntries < 1 {
+		return fmt.Errorf("--ct-entries must be at least 1, not %v", c.CTEntries)
+	}
+
+	if c.ClientKey != "" && c.ClientCert == "" {
+		return errors.New("--client-key requires --client-cert")
+	}
+
+	stateDir := c.StateDir
+	if c.Track && stateDir == "" {
+		if stateDir, err = DefaultStateDir(); err != nil {
+			return err
+		}
+	}
+
 	cert, err := New(c.Addr)
 	if err != nil {
 		return err
@@ -42,48 +210,407 @@ func (c *Command) Run(globals *spiry.Command) (err error) {
 		}
 	}
 
+	cert.Network, err = c.networkOptions(cert.addr)
+	if err != nil {
+		return err
+	}
+
+	if c.CAFile != "" {
+		c.Verify = true
+		cert.Roots, err = LoadCAFile(c.CAFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	cert.VerifyChain = c.Verify
+	cert.Chain = c.Chain
+	cert.Detailed = c.Details
+	cert.CheckName = c.CheckName
+	cert.ListSANs = c.ListSANs || c.CheckSANs
+	cert.CheckSANs = c.CheckSANs
+	cert.RenewalWindow = c.Renewal
+	cert.RenewalThreshold = c.RenewAt / 100
+	cert.CheckDANE = c.DANE
+	cert.CheckCAA = c.CAA
+	cert.Resolver = c.Resolver
+	cert.ACMEDirectory = c.ARI
+	cert.CTLog = c.CTLog
+	cert.CTEntries = c.CTEntries
+	cert.StateDir = stateDir
+	cert.Lint = c.Lint || len(c.SkipLints) > 0
+	cert.SkipLints = c.SkipLints
+	cert.ChaseAIA = c.ChaseAIA
+	cert.AllAddresses = c.AllAddrs
+
+	if c.ClientCert != "" {
+		cert.ClientCertificate, err = LoadClientCertificate(c.ClientCert, c.ClientKey, c.ClientPassword)
+		if err != nil {
+			return err
+		}
+	}
+
+	cert.QUIC = c.QUIC
+	cert.CompareQUIC = c.CompareQUIC
+	cert.KeyTypes = c.KeyTypes
+	cert.TLSVersion = tlsVersions[c.TLSVersion]
+	cert.CheckRevocation = c.Revocation
+	cert.StapleExpiry = c.StapleExpiry
+	cert.ALPN = c.ALPN
+	if c.StartTLS != "" {
+		cert.StartTLS = c.StartTLS
+	}
+
 	output, err := globals.Render(cert)
 	fmt.Println(output)
+	if err != nil {
+		return err
+	}
+
+	if c.Verify {
+		verification, err := cert.Verify()
+		if err != nil {
+			return err
+		}
+		if !verification.Verified {
+			return fmt.Errorf("certificate for %v failed verification", cert.Name())
+		}
+	}
+
+	if c.CheckName {
+		coverage, err := cert.NameCoverage()
+		if err != nil {
+			return err
+		}
+		if !coverage.Covered {
+			return fmt.Errorf("certificate for %v does not cover %v", cert.addr, coverage.Name)
+		}
+	}
+
+	if c.CheckSANs {
+		sans, err := cert.SANs()
+		if err != nil {
+			return err
+		}
+		var uncovered []string
+		for _, san := range sans {
+			if san.Uncovered() {
+				uncovered = append(uncovered, san.Value)
+			}
+		}
+		if len(uncovered) > 0 {
+			return fmt.Errorf("certificates served for %s aren't valid for those names", strings.Join(uncovered, ", "))
+		}
+	}
+
+	if c.FailOverdue {
+		renewal, err := cert.Renewal()
+		if err != nil {
+			return err
+		}
+		if renewal.Overdue {
+			return fmt.Errorf("certificate for %v is overdue for renewal: %.1f%% of its lifetime consumed",
+				cert.Name(), renewal.Consumed)
+		}
+	}
+
+	if cert.Lint {
+		findings, err := cert.Findings()
+		if err != nil {
+			return err
+		}
+		if failed := lint.Failed(findings); len(failed) > 0 {
+			return fmt.Errorf("certificate for %v failed lint checks: %s", cert.Name(), strings.Join(failed, ", "))
+		}
+	}
+
+	if c.DANE {
+		dane, err := cert.DANE()
+		if err != nil {
+			return err
+		}
+		if len(dane.Records) > 0 && !dane.Authenticated {
+			return fmt.Errorf("TLSA records at %v are not DNSSEC authenticated", dane.Name)
+		}
+		if len(dane.Records) > 0 && !dane.Valid {
+			return fmt.Errorf("certificate for %v matches none of the TLSA records at %v", cert.Name(), dane.Name)
+		}
+	}
+
+	if cert.StateDir != "" {
+		if _, err := cert.Track(); err != nil {
+			return err
+		}
+	}
+
+	if c.Revocation {
+		revocation, err := cert.Revocation()
+		if err != nil {
+			return err
+		}
+		if revocation.Revoked() {
+			return fmt.Errorf("certificate for %v has been revoked", cert.Name())
+		}
+	}
 
 	return err
 }
 
+// networkOptions returns the NetworkOptions selected on the command line
+// for connections to addr
+func (c *Command) networkOptions(addr string) (opts NetworkOptions, err error) {
+	opts, err = c.NetworkFlags.Options(&url.URL{Scheme: "https", Host: addr})
+	if err != nil {
+		return opts, err
+	}
+
+	opts.Retries = c.Retries
+	opts.RetryBackoff = c.RetryBackoff
+	opts.Resolve, err = ParseResolve(c.Resolve)
+	return opts, err
+}
+
 func New(address string) (cert *Certificate, err error) {
 	addr, err := parseAddr(address)
 	if err != nil {
 		return cert, err
 	}
-	return &Certificate{addr: addr}, err
+	return &Certificate{addr: addr, StartTLS: protocolFromScheme(address)}, err
 }
 
-func NewWithName(name string, addr string) (*Certificate, error) {
+func NewWithName(name string, address string) (*Certificate, error) {
 	if !govalidator.IsDNSName(name) {
 		slog.Debug("invalid DNS name given", "name", name)
 		return nil, fmt.Errorf("%q is an invalid DNS name", name)
 	}
 
-	addr, err := parseAddr(addr)
+	addr, err := parseAddr(address)
 	if err != nil {
 		return nil, err
 	}
-	return &Certificate{addr: addr, name: name}, err
+	return &Certificate{addr: addr, name: name, StartTLS: protocolFromScheme(address)}, err
 }
 
 func (c *Certificate) Expiry() (time.Time, error) {
 	// if NotAfter already has a valid value, use it
-	if c.raw != nil && !c.raw.NotAfter.IsZero() {
-		return c.raw.NotAfter, nil
+	if c.raw == nil || c.raw.NotAfter.IsZero() {
+		if err := c.retrieve(); err != nil {
+			// no cert to read time from, so use time.Time's zero value
+			return time.Time{},
+				fmt.Errorf("unable to retrieve certificate for %v: %w", c.addr, err)
+		}
 	}
 
-	cert, err := c.getCert()
-	if err != nil {
-		// no cert to read time from, so use time.Time's zero value
-		return time.Time{},
-			fmt.Errorf("unable to retrieve certificate for %v: %w", c.addr, err)
+	chains := [][]*x509.Certificate{c.fullChain(), c.quicChain}
+	for _, k := range c.keyTypes {
+		chains = append(chains, k.Chain)
+	}
+
+	expiry := c.earliestOf(chains...)
+	if c.StapleExpiry {
+		// a lapsed OCSP staple breaks clients that require one,
+		// just as an expired certificate does
+		if nextUpdate := c.session.OCSPNextUpdate; !nextUpdate.IsZero() && nextUpdate.Before(expiry) {
+			return nextUpdate, nil
+		}
+	}
+
+	return expiry, nil
+}
+
+// retrieve fetches the certificate chain from the server, or from every
+// address the server's name resolves to if AllAddresses is set.
+func (c *Certificate) retrieve() (err error) {
+	if c.AllAddresses {
+		err = c.retrieveAll()
+	} else {
+		var state tls.ConnectionState
+		if state, err = c.getState(c.addr, c.tlsConfig()); err == nil {
+			c.setState(state)
+		}
+	}
+
+	if err == nil && c.ChaseAIA {
+		c.completion = c.completeChain(c.chain)
+	}
+
+	if err == nil && c.CompareQUIC {
+		c.compareQUIC()
+	}
+
+	if err == nil && c.KeyTypes {
+		c.retrieveKeyTypes()
+	}
+
+	return
+}
+
+// chainExpiry returns the effective expiration date of chain, which
+// is the leaf certificate's unless Chain is set
+func (c *Certificate) chainExpiry(chain []*x509.Certificate) time.Time {
+	if c.Chain {
+		return EarliestExpiry(chain)
+	}
+
+	return chain[0].NotAfter
+}
+
+// Report describes everything that was asked of the certificate
+// besides its expiration date, and satisfies spiry.Reporter.
+func (c *Certificate) Report(format func(time.Time) string) (fields map[string]any, lines []string) {
+	fields = map[string]any{}
+	if c.raw == nil {
+		return
+	}
+
+	var detailLines []string
+	fields["certificate"], detailLines = DetailsOf(c.raw).report(format)
+	var sessionLines []string
+	fields["session"], sessionLines = c.session.report(format)
+	if c.Detailed {
+		lines = append(lines, detailLines...)
+		lines = append(lines, sessionLines...)
+	}
+
+	if c.Chain {
+		var chainLines []string
+		fields["chain"], chainLines = DescribeChain(c.chain, format)
+		lines = append(lines, chainLines...)
+	}
+
+	if c.CheckName {
+		if cov, err := c.NameCoverage(); err == nil {
+			var coverageLines []string
+			fields["nameCoverage"], coverageLines = cov.report()
+			lines = append(lines, coverageLines...)
+		}
+	}
+
+	if c.ListSANs {
+		if _, err := c.SANs(); err == nil {
+			var sanLines []string
+			fields["sans"], sanLines = c.reportSANs(format)
+			lines = append(lines, sanLines...)
+		}
+	}
+
+	if c.RenewalWindow {
+		if r, err := c.Renewal(); err == nil {
+			var renewalLines []string
+			fields["renewal"], renewalLines = r.report(format)
+			lines = append(lines, renewalLines...)
+		}
+	}
+
+	if c.Lint {
+		if findings, err := c.Findings(); err == nil {
+			fields["lint"] = findings
+			for _, f := range findings {
+				lines = append(lines, "  lint "+f.String())
+			}
+		}
+	}
+
+	if c.CheckDANE {
+		var daneLines []string
+		if d, err := c.DANE(); err != nil {
+			fields["dane"] = map[string]any{"error": err.Error()}
+			daneLines = []string{fmt.Sprintf("  DANE: %v", err)}
+		} else {
+			fields["dane"], daneLines = d.report(format)
+		}
+		lines = append(lines, daneLines...)
+	}
+
+	if c.CheckCAA {
+		var caaLines []string
+		if a, err := c.CAA(); err != nil {
+			fields["caa"] = map[string]any{"error": err.Error()}
+			caaLines = []string{fmt.Sprintf("  CAA: %v", err)}
+		} else {
+			fields["caa"], caaLines = a.report(format)
+		}
+		lines = append(lines, caaLines...)
 	}
-	c.raw = cert
 
-	return c.raw.NotAfter, err
+	if c.ACMEDirectory != "" {
+		var ariLines []string
+		if info, err := c.RenewalInfo(); err != nil {
+			fields["ari"] = map[string]any{"directory": c.ACMEDirectory, "error": err.Error()}
+			ariLines = []string{fmt.Sprintf("  ARI: %v", err)}
+		} else {
+			fields["ari"], ariLines = info.report(format)
+		}
+		lines = append(lines, ariLines...)
+	}
+
+	if c.CTLog != "" {
+		var ctLines []string
+		if t, err := c.Transparency(); err != nil {
+			fields["ct"] = map[string]any{"log": c.CTLog, "error": err.Error()}
+			ctLines = []string{fmt.Sprintf("  ct: %v", err)}
+		} else {
+			fields["ct"], ctLines = t.report(format)
+		}
+		lines = append(lines, ctLines...)
+	}
+
+	if c.StateDir != "" {
+		var trackLines []string
+		if t, err := c.Track(); err != nil {
+			fields["track"] = map[string]any{"error": err.Error()}
+			trackLines = []string{fmt.Sprintf("  tracked: %v", err)}
+		} else {
+			fields["track"], trackLines = t.report(format)
+		}
+		lines = append(lines, trackLines...)
+	}
+
+	if c.ChaseAIA {
+		var aiaLines []string
+		fields["aia"], aiaLines = c.completion.report(format)
+		lines = append(lines, aiaLines...)
+	}
+
+	if c.AllAddresses {
+		var backendLines []string
+		fields["addresses"], backendLines = c.reportBackends(format)
+		lines = append(lines, backendLines...)
+	}
+
+	if c.CompareQUIC {
+		var quicLines []string
+		fields["quic"], quicLines = c.reportQUIC(format)
+		lines = append(lines, quicLines...)
+	}
+
+	if c.KeyTypes {
+		var keyTypeLines []string
+		fields["keyTypes"], keyTypeLines = c.reportKeyTypes(format)
+		lines = append(lines, keyTypeLines...)
+	}
+
+	if client, clientLines := c.reportClient(format); client != nil {
+		fields["clientCertificate"] = client
+		lines = append(lines, clientLines...)
+	}
+
+	if c.VerifyChain {
+		if v, err := c.Verify(); err == nil {
+			fields["verification"] = v
+			lines = append(lines, v.describe()...)
+		}
+	}
+
+	if c.CheckRevocation {
+		if r, err := c.Revocation(); err == nil {
+			var revocationLines []string
+			fields["revocation"], revocationLines = r.report(format)
+			lines = append(lines, revocationLines...)
+		}
+	}
+
+	return
 }
 
 func (c *Certificate) Name() (name string) {
@@ -95,7 +622,29 @@ func (c *Certificate) Name() (name string) {
 	return
 }
 
-func (c *Certificate) getCert() (cert *x509.Certificate, err error) {
+func (c *Certificate) getChain(addr string) (chain []*x509.Certificate, err error) {
+	state, err := c.getState(addr, c.tlsConfig())
+	return state.PeerCertificates, err
+}
+
+// getState completes a handshake with the server at addr using
+// tlsConfig, and returns the state of the resulting connection
+func (c *Certificate) getState(addr string, tlsConfig *tls.Config) (state tls.ConnectionState, err error) {
+	state, err = c.handshake(addr, tlsConfig)
+	if err != nil {
+		return
+	}
+
+	if len(state.PeerCertificates) == 0 {
+		return state, fmt.Errorf("no certificates presented by %v", addr)
+	}
+
+	return
+}
+
+// tlsConfig returns the configuration used for TLS handshakes
+// with the server
+func (c *Certificate) tlsConfig() *tls.Config {
 	tlsConfig := &tls.Config{
 		// this is intentionally done to allow
 		// retrieval of any TLS certificate -- we only
@@ -103,24 +652,24 @@ func (c *Certificate) getCert() (cert *x509.Certificate, err error) {
 		InsecureSkipVerify: true,
 		ServerName:         c.Name()}
 
-	dialer := &net.Dialer{
-		Timeout: time.Millisecond * time.Duration(1000),
+	if c.TLSVersion != 0 {
+		tlsConfig.MinVersion = c.TLSVersion
+		tlsConfig.MaxVersion = c.TLSVersion
 	}
 
-	conn, err := tls.DialWithDialer(dialer, "tcp", c.addr, tlsConfig)
-	if err != nil {
-		return
+	if c.ALPN != "" {
+		tlsConfig.NextProtos = []string{c.ALPN}
 	}
 
-	defer func() { _ = conn.Close() }()
+	if c.ClientCertificate != nil {
+		tlsConfig.GetClientCertificate = c.getClientCertificate
+	}
 
-	certs := conn.ConnectionState().PeerCertificates
-	cert = certs[0]
-	return
+	return tlsConfig
 }
 
 func parseAddr(addr string) (parsedAddress string, err error) {
-	if govalidator.IsURL(addr) {
+	if govalidator.IsURL(addr) || protocolFromScheme(addr) != "" {
 		parsedAddress, err = parseAsURL(addr)
 		if err == nil {
 			return
@@ -206,6 +755,14 @@ func parseAsURL(addr string) (parsedAddress string, err error) {
 	port, err := net.LookupPort("tcp", u.Scheme)
 	if err == nil {
 		parsedAddress = net.JoinHostPort(u.Hostname(), fmt.Sprint(port))
+		return
+	}
+
+	// not every system knows every service name, so fall back
+	// on the well-known ports of any protocols we can upgrade
+	if proto, ok := protocols[strings.ToLower(u.Scheme)]; ok {
+		parsedAddress = net.JoinHostPort(u.Hostname(), proto.port)
+		err = nil
 	}
 
 	return
@@ -1,6 +1,12 @@
 package certificate_test
 
 import (
+	"crypto/tls"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/likexian/gokit/assert"
@@ -86,3 +92,42 @@ func TestNew(t *testing.T) {
 		})
 	}
 }
+
+func TestReport(t *testing.T) {
+	pki := newTestPKI(t)
+	server := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(server.Close)
+
+	cert, err := certificate.New(serveTLS(t, &tls.Config{Certificates: []tls.Certificate{pki.tlsCertificate()}}))
+	assert.Nil(t, err, "a host:port pair should parse")
+
+	fields, lines := cert.Report(rfc3339)
+	assert.Equal(t, len(fields), 0, "nothing should be reported before the certificate is retrieved")
+
+	_, err = cert.Expiry()
+	assert.Nil(t, err, "the certificate should be retrieved")
+
+	fields, lines = cert.Report(rfc3339)
+	assert.Equal(t, slices.Sorted(maps.Keys(fields)), []string{"certificate", "session"},
+		"the certificate and session should always be reported as JSON fields")
+	assert.Equal(t, len(lines), 0, "nothing should be displayed unless asked for")
+
+	cert.Detailed = true
+	_, lines = cert.Report(rfc3339)
+	assert.Contains(t, strings.Join(lines, "\n"), certificate.DetailsOf(pki.leaf.cert).Fingerprint,
+		"details should be displayed when asked for")
+
+	cert.Detailed = false
+	cert.Chain = true
+	cert.CheckName = true
+	cert.RenewalWindow = true
+	cert.Lint = true
+	cert.CTLog = server.URL
+
+	fields, lines = cert.Report(rfc3339)
+	assert.Equal(t, slices.Sorted(maps.Keys(fields)),
+		[]string{"certificate", "chain", "ct", "lint", "nameCoverage", "renewal", "session"},
+		"every section asked for should be reported as a JSON field")
+	assert.Contains(t, fields["ct"].(map[string]any)["error"], "404", "a failed section should be reported as an error")
+	assert.Contains(t, lines[len(lines)-1], "404", "a failed section should be reported in plain output")
+}
@@ -0,0 +1,64 @@
+package certificate
+
+import (
+	"crypto/x509"
+	"fmt"
+	"time"
+)
+
+// PeerCertificates returns every certificate presented by the server,
+// starting with the leaf certificate. The certificates are retrieved
+// on first use.
+func (c *Certificate) PeerCertificates() ([]*x509.Certificate, error) {
+	if _, err := c.Expiry(); err != nil {
+		return nil, err
+	}
+
+	return c.chain, nil
+}
+
+// DescribeChain describes each of the given certificates, both as
+// JSON fields and as lines of plain output, using format for any times.
+func DescribeChain(certs []*x509.Certificate, format func(time.Time) string) (links []map[string]string, lines []string) {
+	links = make([]map[string]string, 0, len(certs))
+	for i, cert := range certs {
+		links = append(links, map[string]string{
+			"subject":   cert.Subject.String(),
+			"issuer":    cert.Issuer.String(),
+			"notBefore": format(cert.NotBefore),
+			"notAfter":  format(cert.NotAfter),
+		})
+		lines = append(lines, fmt.Sprintf("  %d\tsubject=%q\tissuer=%q\tnotBefore=%s\tnotAfter=%s",
+			i, cert.Subject, cert.Issuer, format(cert.NotBefore), format(cert.NotAfter)))
+	}
+
+	return
+}
+
+// EarliestExpiry returns the earliest NotAfter date of the given
+// certificates, which is the effective expiration date of the chain.
+func EarliestExpiry(certs []*x509.Certificate) (expiry time.Time) {
+	for _, cert := range certs {
+		if expiry.IsZero() || cert.NotAfter.Before(expiry) {
+			expiry = cert.NotAfter
+		}
+	}
+
+	return
+}
+
+// earliestOf returns the earliest effective expiration date
+// of the given chains, ignoring any that are empty
+func (c *Certificate) earliestOf(chains ...[]*x509.Certificate) (expiry time.Time) {
+	for _, chain := range chains {
+		if len(chain) == 0 {
+			continue
+		}
+
+		if chainExpiry := c.chainExpiry(chain); expiry.IsZero() || chainExpiry.Before(expiry) {
+			expiry = chainExpiry
+		}
+	}
+
+	return
+}
@@ -0,0 +1,37 @@
+package certificate_test
+
+import (
+	"crypto/tls"
+	"testing"
+	"time"
+
+	"github.com/likexian/gokit/assert"
+	"github.com/mckern/spiry/internal/certificate"
+)
+
+func TestChainExpiry(t *testing.T) {
+	pki := newTestPKI(t)
+	addr := serveTLS(t, &tls.Config{Certificates: []tls.Certificate{pki.tlsCertificate()}})
+
+	cert, err := certificate.New(addr)
+	assert.Nil(t, err, "a loopback address should parse")
+
+	expiry, err := cert.Expiry()
+	assert.Nil(t, err, "a certificate should be retrieved")
+	assert.True(t, expiry.Equal(pki.leaf.cert.NotAfter), "the leaf expiry should be used by default")
+
+	cert.Chain = true
+	expiry, err = cert.Expiry()
+	assert.Nil(t, err, "a certificate chain should be retrieved")
+	assert.True(t, expiry.Equal(pki.intermediate.cert.NotAfter), "the earliest expiry in the chain should be used")
+
+	chain, err := cert.PeerCertificates()
+	assert.Nil(t, err, "the presented chain should be available")
+	assert.Equal(t, len(chain), 2, "both the leaf and intermediate should be presented")
+
+	links, lines := certificate.DescribeChain(chain, rfc3339)
+	assert.Equal(t, len(lines), 2, "every certificate in the chain should be reported")
+	assert.Equal(t, links[1]["subject"], "CN=spiry test intermediate", "the intermediate should be described")
+	assert.Equal(t, links[1]["notAfter"], pki.intermediate.cert.NotAfter.Format(time.RFC3339),
+		"the requested time format should be used")
+}
@@ -0,0 +1,102 @@
+package certificate
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// LoadClientCertificate loads a certificate and private key to present
+// to servers that require mutual TLS. The certificate and key may be
+// PEM files, a single PEM file containing both if keyPath is empty, or
+// a PKCS#12 archive decrypted with password if keyPath is empty.
+func LoadClientCertificate(certPath string, keyPath string, password string) (*tls.Certificate, error) {
+	if keyPath != "" {
+		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %w", err)
+		}
+		return &cert, nil
+	}
+
+	data, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if block, _ := pem.Decode(data); block != nil {
+		cert, err := tls.X509KeyPair(data, data)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate and key from %v: %w", certPath, err)
+		}
+		return &cert, nil
+	}
+
+	key, leaf, caCerts, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load client certificate from PKCS#12 archive %v: %w", certPath, err)
+	}
+
+	cert := &tls.Certificate{
+		Certificate: [][]byte{leaf.Raw},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}
+	for _, ca := range caCerts {
+		cert.Certificate = append(cert.Certificate, ca.Raw)
+	}
+
+	return cert, nil
+}
+
+// clientLeaf returns the parsed leaf of the client certificate,
+// or nil if there is no client certificate
+func (c *Certificate) clientLeaf() *x509.Certificate {
+	if c.ClientCertificate == nil || len(c.ClientCertificate.Certificate) == 0 {
+		return nil
+	}
+
+	if c.ClientCertificate.Leaf != nil {
+		return c.ClientCertificate.Leaf
+	}
+
+	leaf, err := x509.ParseCertificate(c.ClientCertificate.Certificate[0])
+	if err != nil {
+		return nil
+	}
+
+	return leaf
+}
+
+// reportClient describes the client certificate, which
+// expires and breaks things just like the server's does
+func (c *Certificate) reportClient(format func(time.Time) string) (fields map[string]string, lines []string) {
+	leaf := c.clientLeaf()
+	if leaf == nil {
+		return nil, nil
+	}
+
+	d := DetailsOf(leaf)
+	fields = map[string]string{
+		"subject":           d.Subject,
+		"issuer":            d.Issuer,
+		"notBefore":         format(d.NotBefore),
+		"notAfter":          format(d.NotAfter),
+		"sha256Fingerprint": d.Fingerprint,
+	}
+	lines = append(lines, fmt.Sprintf("  client certificate\tsubject=%q\tnotAfter=%s",
+		d.Subject, format(d.NotAfter)))
+
+	return
+}
+
+// getClientCertificate always presents the client certificate,
+// even if the server asks for one issued by a different CA
+func (c *Certificate) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return c.ClientCertificate, nil
+}
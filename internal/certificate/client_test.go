@@ -0,0 +1,122 @@
+package certificate_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/likexian/gokit/assert"
+	"github.com/mckern/spiry/internal/certificate"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// newClientCert issues a client certificate from the test PKI's
+// intermediate, and writes it out in every supported format
+func newClientCert(t *testing.T, pki *testPKI) (client testCert, certPEM, keyPEM, bundlePEM, archive string) {
+	t.Helper()
+
+	now := time.Now().Truncate(time.Second)
+	client = newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "spiry test client"},
+		NotBefore:   now.Add(-time.Hour),
+		NotAfter:    now.AddDate(0, 0, 14),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, &pki.intermediate)
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(client.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certData := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: client.cert.Raw})
+	keyData := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	archiveData, err := pkcs12.Modern.Encode(client.key, client.cert, []*x509.Certificate{pki.intermediate.cert}, "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	return client,
+		write("client.crt", certData),
+		write("client.key", keyData),
+		write("client.pem", append(append([]byte{}, keyData...), certData...)),
+		write("client.p12", archiveData)
+}
+
+func TestClientCertificate(t *testing.T) {
+	pki := newTestPKI(t)
+	client, certPEM, keyPEM, bundlePEM, archive := newClientCert(t, pki)
+
+	// PEM client certificates are presented without their intermediate
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(pki.intermediate.cert)
+
+	presented := make(chan *x509.Certificate, 10)
+	addr := serveTLS(t, &tls.Config{
+		Certificates: []tls.Certificate{pki.tlsCertificate()},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			presented <- state.PeerCertificates[0]
+			return nil
+		},
+	})
+
+	var clientTests = []struct {
+		name     string
+		certPath string
+		keyPath  string
+		password string
+	}{
+		{name: "a PEM certificate and key", certPath: certPEM, keyPath: keyPEM},
+		{name: "a PEM bundle with both certificate and key", certPath: bundlePEM},
+		{name: "a PKCS#12 archive", certPath: archive, password: "hunter2"},
+	}
+
+	for _, tt := range clientTests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientCert, err := certificate.LoadClientCertificate(tt.certPath, tt.keyPath, tt.password)
+			assert.Nil(t, err, "a client certificate should be loaded")
+
+			cert, _ := certificate.New(addr)
+			cert.ClientCertificate = clientCert
+
+			expiry, err := cert.Expiry()
+			assert.Nil(t, err, "a certificate should be retrieved with a client certificate")
+			assert.True(t, expiry.Equal(pki.leaf.cert.NotAfter), "the server's certificate should be reported")
+			select {
+			case got := <-presented:
+				assert.Equal(t, got.Raw, client.cert.Raw, "the client certificate should be presented")
+			case <-time.After(time.Second):
+				t.Fatal("the server never verified a client certificate")
+			}
+
+			reported, lines := certificate.ReportClient(cert, rfc3339)
+			assert.Equal(t, reported["notAfter"], client.cert.NotAfter.Format(time.RFC3339),
+				"the client certificate's expiration date should be reported")
+			assert.Equal(t, len(lines), 1, "the client certificate should be reported in plain output")
+		})
+	}
+}
+
+func TestClientCertificateWrongPassword(t *testing.T) {
+	pki := newTestPKI(t)
+	_, _, _, _, archive := newClientCert(t, pki)
+
+	_, err := certificate.LoadClientCertificate(archive, "", "hunter3")
+	assert.NotNil(t, err, "a PKCS#12 archive with the wrong password should raise an error")
+}
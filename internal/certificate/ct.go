@@ -0,0 +1,292 @@
+package certificate
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// defaultCTEntries is how many of a CT log's most recent entries are
+// searched, when no other number is given
+const defaultCTEntries = 1000
+
+// entry types of a MerkleTreeLeaf, per RFC 6962, section 3.4
+const (
+	ctX509Entry    = 0
+	ctPrecertEntry = 1
+)
+
+// Transparency is the outcome of searching a Certificate Transparency
+// log for certificates issued for the names the leaf certificate covers
+type Transparency struct {
+	Log      string
+	TreeSize uint64
+	// Searched is the number of log entries that were searched
+	Searched int
+	// Logged is set when the leaf certificate was found in the log
+	Logged bool
+	// Newer holds certificates from the same CA that were issued after
+	// the leaf certificate, but are not being served. CAs rotate their
+	// intermediates, so these may have been issued by a different one.
+	Newer []*x509.Certificate
+	// Unexpected holds certificates from any other CA
+	Unexpected []*x509.Certificate
+}
+
+// sth is a signed tree head, as returned by get-sth
+type sth struct {
+	TreeSize uint64 `json:"tree_size"`
+}
+
+// ctEntries are log entries, as returned by get-entries
+type ctEntries struct {
+	Entries []struct {
+		LeafInput []byte `json:"leaf_input"`
+		ExtraData []byte `json:"extra_data"`
+	} `json:"entries"`
+}
+
+// Transparency searches the most recent CTEntries entries of the
+// RFC 6962 log at CTLog for certificates covering any of the leaf
+// certificate's names, and compares them with the leaf
+func (c *Certificate) Transparency() (*Transparency, error) {
+	if c.transparency != nil {
+		return c.transparency, nil
+	}
+
+	if _, err := c.Expiry(); err != nil {
+		return nil, err
+	}
+
+	if c.CTLog == "" {
+		return nil, errors.New("no CT log URL given")
+	}
+
+	t, err := c.searchCT(c.CTLog, c.raw)
+	if err != nil {
+		return nil, fmt.Errorf("unable to search CT log %v: %w", c.CTLog, err)
+	}
+
+	c.transparency = t
+	return c.transparency, nil
+}
+
+func (c *Certificate) searchCT(log string, leaf *x509.Certificate) (*Transparency, error) {
+	base, err := url.Parse(strings.TrimSuffix(log, "/") + "/ct/v1/")
+	if err != nil {
+		return nil, err
+	}
+
+	var head sth
+	if err := c.getJSON(base.JoinPath("get-sth").String(), &head); err != nil {
+		return nil, err
+	}
+
+	count := uint64(defaultCTEntries)
+	if c.CTEntries > 0 {
+		count = uint64(c.CTEntries)
+	}
+
+	t := &Transparency{Log: log, TreeSize: head.TreeSize}
+	start := uint64(0)
+	if head.TreeSize > count {
+		start = head.TreeSize - count
+	}
+
+	names := certNames(leaf)
+	seen := map[string]bool{}
+
+	// logs may return fewer entries than asked for,
+	// so keep asking until the tree head is reached
+	for start < head.TreeSize {
+		query := url.Values{
+			"start": {fmt.Sprint(start)},
+			"end":   {fmt.Sprint(head.TreeSize - 1)},
+		}
+
+		var batch ctEntries
+		if err := c.getJSON(base.JoinPath("get-entries").String()+"?"+query.Encode(), &batch); err != nil {
+			return nil, err
+		}
+
+		if len(batch.Entries) == 0 {
+			return nil, fmt.Errorf("no entries returned from %d", start)
+		}
+
+		for _, entry := range batch.Entries {
+			start++
+			t.Searched++
+
+			cert, err := parseCTEntry(entry.LeafInput, entry.ExtraData)
+			if err != nil {
+				slog.Debug("skipping CT log entry", "index", start-1, "error", err)
+				continue
+			}
+
+			// precertificates and their final certificates share a serial
+			// number and issuer, so each issuance is only counted once
+			key := fmt.Sprintf("%x/%x/%s", cert.RawIssuer, cert.AuthorityKeyId, cert.SerialNumber)
+			if seen[key] || !slices.ContainsFunc(certNames(cert), func(name string) bool { return slices.Contains(names, name) }) {
+				continue
+			}
+			seen[key] = true
+
+			switch {
+			case sameIssuance(cert, leaf):
+				t.Logged = true
+			case !sameCA(cert, leaf):
+				t.Unexpected = append(t.Unexpected, cert)
+			case cert.NotBefore.After(leaf.NotBefore):
+				t.Newer = append(t.Newer, cert)
+			}
+		}
+	}
+
+	return t, nil
+}
+
+// getJSON fetches the JSON document at url into v
+func (c *Certificate) getJSON(url string, v any) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	data, err := c.Network.Fetch(req)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// parseCTEntry returns the certificate logged in a MerkleTreeLeaf. For
+// precertificate entries, the precertificate itself is read from the
+// PrecertChainEntry in extraData.
+func parseCTEntry(leafInput []byte, extraData []byte) (*x509.Certificate, error) {
+	// version, leaf type and timestamp come before the entry type
+	if len(leafInput) < 12 {
+		return nil, errors.New("leaf input is too short")
+	}
+
+	var der []byte
+	var err error
+	switch binary.BigEndian.Uint16(leafInput[10:12]) {
+	case ctX509Entry:
+		der, _, err = readOpaque24(leafInput[12:])
+	case ctPrecertEntry:
+		der, _, err = readOpaque24(extraData)
+	default:
+		return nil, errors.New("unknown entry type")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return x509.ParseCertificate(der)
+}
+
+// readOpaque24 reads a TLS opaque vector with a 24-bit length prefix
+func readOpaque24(b []byte) (value []byte, rest []byte, err error) {
+	if len(b) < 3 {
+		return nil, nil, errors.New("truncated length")
+	}
+
+	n := int(b[0])<<16 | int(b[1])<<8 | int(b[2])
+	if len(b) < 3+n {
+		return nil, nil, errors.New("truncated value")
+	}
+
+	return b[3 : 3+n], b[3+n:], nil
+}
+
+// certNames returns the DNS names cert covers, in lower case
+func certNames(cert *x509.Certificate) []string {
+	names := make([]string, 0, len(cert.DNSNames))
+	for _, name := range cert.DNSNames {
+		names = append(names, strings.ToLower(name))
+	}
+
+	return names
+}
+
+// sameIssuer reports whether a and b were issued by the same CA,
+// which takes the same name and key
+func sameIssuer(a *x509.Certificate, b *x509.Certificate) bool {
+	return bytes.Equal(a.RawIssuer, b.RawIssuer) && bytes.Equal(a.AuthorityKeyId, b.AuthorityKeyId)
+}
+
+// sameCA reports whether a and b were issued by the same CA, if not
+// necessarily the same intermediate: either by the same issuer, or by
+// issuers belonging to the same organization
+func sameCA(a *x509.Certificate, b *x509.Certificate) bool {
+	if sameIssuer(a, b) {
+		return true
+	}
+
+	return len(a.Issuer.Organization) > 0 && slices.Equal(a.Issuer.Organization, b.Issuer.Organization)
+}
+
+// sameIssuance reports whether a and b are the same issuance: either the
+// same certificate, or a precertificate and its final certificate
+func sameIssuance(a *x509.Certificate, b *x509.Certificate) bool {
+	return a.SerialNumber.Cmp(b.SerialNumber) == 0 && sameIssuer(a, b)
+}
+
+// report returns t as JSON fields and lines of plain output,
+// using format for any times
+func (t *Transparency) report(format func(time.Time) string) (fields map[string]any, lines []string) {
+	describe := func(certs []*x509.Certificate) []map[string]string {
+		described := make([]map[string]string, 0, len(certs))
+		for _, cert := range certs {
+			described = append(described, map[string]string{
+				"issuer":       cert.Issuer.String(),
+				"serialNumber": colonHex(cert.SerialNumber.Bytes()),
+				"notBefore":    format(cert.NotBefore),
+				"notAfter":     format(cert.NotAfter),
+			})
+		}
+		return described
+	}
+
+	fields = map[string]any{
+		"log":        t.Log,
+		"treeSize":   t.TreeSize,
+		"searched":   t.Searched,
+		"logged":     t.Logged,
+		"newer":      describe(t.Newer),
+		"unexpected": describe(t.Unexpected),
+	}
+
+	// only the most recent entries are searched, so the served
+	// certificate will rarely be found unless it is very new
+	logged := "not among them"
+	if t.Logged {
+		logged = "found"
+	}
+	lines = append(lines, fmt.Sprintf("  ct: searched only the most recent %d of %d entries in %s, served certificate %s",
+		t.Searched, t.TreeSize, t.Log, logged))
+
+	for _, cert := range t.Newer {
+		lines = append(lines, fmt.Sprintf("  ct renewed but not deployed: serial=%s\tnotBefore=%s\tnotAfter=%s",
+			colonHex(cert.SerialNumber.Bytes()), format(cert.NotBefore), format(cert.NotAfter)))
+	}
+
+	for _, cert := range t.Unexpected {
+		lines = append(lines, fmt.Sprintf("  ct unexpected issuance: issuer=%q\tserial=%s\tnotBefore=%s",
+			cert.Issuer, colonHex(cert.SerialNumber.Bytes()), format(cert.NotBefore)))
+	}
+
+	return
+}
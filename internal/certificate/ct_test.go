@@ -0,0 +1,181 @@
+package certificate_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/likexian/gokit/assert"
+	"github.com/mckern/spiry/internal/certificate"
+)
+
+// ctEntry is a log entry, as served by get-entries
+type ctEntry struct {
+	LeafInput []byte `json:"leaf_input"`
+	ExtraData []byte `json:"extra_data"`
+}
+
+// opaque24 encodes b as a TLS opaque vector with a 24-bit length prefix
+func opaque24(b []byte) []byte {
+	return append([]byte{byte(len(b) >> 16), byte(len(b) >> 8), byte(len(b))}, b...)
+}
+
+// newCTEntry encodes cert as an RFC 6962 log entry, either as
+// an X.509 entry or as a precertificate entry
+func newCTEntry(cert *x509.Certificate, precert bool) ctEntry {
+	leaf := []byte{0, 0}
+	leaf = binary.BigEndian.AppendUint64(leaf, uint64(time.Now().UnixMilli()))
+
+	if !precert {
+		leaf = append(leaf, 0, 0)
+		leaf = append(leaf, opaque24(cert.Raw)...)
+		return ctEntry{LeafInput: append(leaf, 0, 0), ExtraData: opaque24(nil)}
+	}
+
+	leaf = append(leaf, 0, 1)
+	leaf = append(leaf, make([]byte, 32)...)
+	leaf = append(leaf, opaque24(cert.RawTBSCertificate)...)
+	return ctEntry{LeafInput: append(leaf, 0, 0), ExtraData: append(opaque24(cert.Raw), opaque24(nil)...)}
+}
+
+// serveCTLog serves entries through the RFC 6962 get-sth and get-entries
+// endpoints, returning at most two entries per request
+func serveCTLog(t *testing.T, entries []ctEntry) string {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /log/ct/v1/get-sth", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"tree_size": len(entries)})
+	})
+	mux.HandleFunc("GET /log/ct/v1/get-entries", func(w http.ResponseWriter, r *http.Request) {
+		start, _ := strconv.Atoi(r.URL.Query().Get("start"))
+		end, _ := strconv.Atoi(r.URL.Query().Get("end"))
+		end = min(end, start+1, len(entries)-1)
+		_ = json.NewEncoder(w).Encode(map[string]any{"entries": entries[start : end+1]})
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server.URL + "/log/"
+}
+
+func TestTransparency(t *testing.T) {
+	pki, other := newTestPKI(t), newTestPKI(t)
+	now := time.Now().Truncate(time.Second)
+
+	newLeaf := func(parent *testCert, name string, notBefore time.Time) *x509.Certificate {
+		return newTestCert(t, &x509.Certificate{
+			Subject:     pkix.Name{CommonName: name},
+			DNSNames:    []string{name},
+			NotBefore:   notBefore,
+			NotAfter:    notBefore.AddDate(0, 0, 90),
+			KeyUsage:    x509.KeyUsageDigitalSignature,
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}, parent).cert
+	}
+
+	older := newLeaf(&pki.intermediate, "localhost", now.AddDate(0, 0, -60))
+	renewed := newLeaf(&pki.intermediate, "localhost", now)
+	unexpected := newLeaf(&other.intermediate, "LOCALHOST", now)
+	unrelated := newLeaf(&pki.intermediate, "example.org", now)
+
+	log := serveCTLog(t, []ctEntry{
+		newCTEntry(older, false),
+		newCTEntry(pki.leaf.cert, true),
+		newCTEntry(pki.leaf.cert, false),
+		newCTEntry(renewed, true),
+		newCTEntry(unexpected, false),
+		newCTEntry(unrelated, false),
+	})
+
+	addr := serveTLS(t, &tls.Config{Certificates: []tls.Certificate{pki.tlsCertificate()}})
+	cert, err := certificate.New(addr)
+	assert.Nil(t, err, "a host:port pair should parse")
+	cert.CTLog = log
+
+	ct, err := cert.Transparency()
+	assert.Nil(t, err, "the CT log should be searched")
+	assert.Equal(t, ct.TreeSize, uint64(6))
+	assert.Equal(t, ct.Searched, 6, "every entry should be searched")
+	assert.True(t, ct.Logged, "the served certificate should be found in the log")
+	assert.Equal(t, len(ct.Newer), 1, "a renewed certificate should be reported")
+	assert.Equal(t, ct.Newer[0].SerialNumber, renewed.SerialNumber)
+	assert.Equal(t, len(ct.Unexpected), 1, "a certificate from another issuer should be reported")
+	assert.Equal(t, ct.Unexpected[0].SerialNumber, unexpected.SerialNumber)
+
+	fields, lines := certificate.ReportOf(ct, rfc3339)
+	assert.Equal(t, len(fields["newer"].([]map[string]string)), 1, "a renewed certificate should be reported as a JSON field")
+	assert.Equal(t, len(lines), 3, "every finding should be reported in plain output")
+	assert.Contains(t, lines[1], "renewed but not deployed")
+	assert.Contains(t, lines[2], "unexpected issuance")
+
+	cert, err = certificate.New(addr)
+	assert.Nil(t, err, "a host:port pair should parse")
+	cert.CTLog = log
+	cert.CTEntries = 2
+
+	ct, err = cert.Transparency()
+	assert.Nil(t, err, "the CT log should be searched")
+	assert.Equal(t, ct.Searched, 2, "only the most recent entries should be searched")
+	assert.False(t, ct.Logged, "entries outside the window should not be searched")
+}
+
+func TestTransparencyRotatedIntermediate(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	newCA := func(name string, parent *testCert) testCert {
+		return newTestCert(t, &x509.Certificate{
+			Subject:               pkix.Name{Organization: []string{"Example CA"}, CommonName: name},
+			NotBefore:             now.Add(-time.Hour),
+			NotAfter:              now.AddDate(1, 0, 0),
+			KeyUsage:              x509.KeyUsageCertSign,
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+		}, parent)
+	}
+	newLeaf := func(parent *testCert, notBefore time.Time) testCert {
+		return newTestCert(t, &x509.Certificate{
+			Subject:     pkix.Name{CommonName: "localhost"},
+			DNSNames:    []string{"localhost"},
+			NotBefore:   notBefore,
+			NotAfter:    notBefore.AddDate(0, 0, 90),
+			KeyUsage:    x509.KeyUsageDigitalSignature,
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}, parent)
+	}
+
+	root := newCA("Example Root", nil)
+	r10, r11 := newCA("R10", &root), newCA("R11", &root)
+	served := newLeaf(&r10, now.AddDate(0, 0, -60))
+	renewed := newLeaf(&r11, now)
+
+	cert, err := certificate.New(serveLeaf(t, &testPKI{root: root, intermediate: r10}, served))
+	assert.Nil(t, err, "a host:port pair should parse")
+	cert.CTLog = serveCTLog(t, []ctEntry{newCTEntry(served.cert, false), newCTEntry(renewed.cert, false)})
+
+	ct, err := cert.Transparency()
+	assert.Nil(t, err, "the CT log should be searched")
+	assert.Equal(t, len(ct.Newer), 1, "a renewal under another of the CA's intermediates should be reported as one")
+	assert.Equal(t, len(ct.Unexpected), 0, "a renewal under another of the CA's intermediates is expected")
+}
+
+func TestTransparencyUnavailable(t *testing.T) {
+	pki := newTestPKI(t)
+	server := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(server.Close)
+
+	cert, err := certificate.New(serveTLS(t, &tls.Config{Certificates: []tls.Certificate{pki.tlsCertificate()}}))
+	assert.Nil(t, err, "a host:port pair should parse")
+	cert.CTLog = server.URL
+
+	_, err = cert.Transparency()
+	assert.NotNil(t, err, "an unavailable log should be reported")
+	assert.Contains(t, err.Error(), "404", "the log's response should be reported")
+}
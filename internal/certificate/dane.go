@@ -0,0 +1,193 @@
+package certificate
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// TLSA certificate usages, per RFC 6698, section 2.1.1. The trust
+// anchor usages name a certificate that issued the end entity
+// certificate rather than the end entity certificate itself, and the
+// PKIX usages also require the chain to pass PKIX validation.
+const (
+	tlsaUsagePKIXTA = 0
+	tlsaUsagePKIXEE = 1
+	tlsaUsageDANETA = 2
+)
+
+// TLSARecord is a TLSA record, and which certificate of
+// the chain presented by the server it matches
+type TLSARecord struct {
+	Usage        uint8
+	Selector     uint8
+	MatchingType uint8
+	Data         string
+	// Matched is set when the record matches a certificate in the
+	// chain, and MatchedIndex is that certificate's position in it
+	Matched      bool
+	MatchedIndex int
+	// Unverified is set when a record with a PKIX usage names a
+	// certificate in the chain, but the chain fails PKIX validation,
+	// so the record does not match
+	Unverified bool
+}
+
+// DANE is the outcome of checking the certificate chain
+// presented by the server against its TLSA records
+type DANE struct {
+	// Name is where the TLSA records were looked up, e.g. _25._tcp.mx.example.com
+	Name    string
+	Records []TLSARecord
+	// Authenticated is set when the resolver validated the
+	// records with DNSSEC, which DANE depends on
+	Authenticated bool
+	// Valid is set when at least one of the records matches
+	Valid bool
+}
+
+// DANE looks up the TLSA records for the server's name and port, and
+// matches them against the certificate chain presented by the server.
+// Records with a PKIX usage only match if the chain also passes Verify.
+func (c *Certificate) DANE() (*DANE, error) {
+	if c.dane != nil {
+		return c.dane, nil
+	}
+
+	chain, err := c.PeerCertificates()
+	if err != nil {
+		return nil, err
+	}
+
+	host := c.Name()
+	if net.ParseIP(host) != nil {
+		return nil, errors.New("TLSA records can only be looked up for host names")
+	}
+
+	_, port, _ := net.SplitHostPort(c.addr)
+	proto := "tcp"
+	if c.QUIC {
+		proto = "udp"
+	}
+	name := fmt.Sprintf("_%s._%s.%s", port, proto, dns.Fqdn(host))
+
+	resp, err := c.lookup(name, dns.TypeTLSA)
+	if err != nil {
+		return nil, fmt.Errorf("unable to look up TLSA records for %v: %w", name, err)
+	}
+
+	d := &DANE{Name: name, Authenticated: resp.AuthenticatedData}
+	for _, rr := range resp.Answer {
+		if tlsa, ok := rr.(*dns.TLSA); ok {
+			record := matchTLSA(tlsa, chain)
+			if record.Matched && (tlsa.Usage == tlsaUsagePKIXTA || tlsa.Usage == tlsaUsagePKIXEE) {
+				v, err := c.Verify()
+				if err != nil {
+					return nil, err
+				}
+				if !v.Verified {
+					record.Matched, record.Unverified = false, true
+				}
+			}
+			d.Valid = d.Valid || record.Matched
+			d.Records = append(d.Records, record)
+		}
+	}
+
+	c.dane = d
+	return c.dane, nil
+}
+
+// matchTLSA matches a TLSA record against chain. End entity usages
+// match only the leaf certificate, and trust anchor usages match
+// only the certificates that issued it.
+func matchTLSA(tlsa *dns.TLSA, chain []*x509.Certificate) TLSARecord {
+	record := TLSARecord{
+		Usage:        tlsa.Usage,
+		Selector:     tlsa.Selector,
+		MatchingType: tlsa.MatchingType,
+		Data:         strings.ToLower(tlsa.Certificate),
+		MatchedIndex: -1,
+	}
+
+	candidates := chain[:1]
+	first := 0
+	if tlsa.Usage == tlsaUsagePKIXTA || tlsa.Usage == tlsaUsageDANETA {
+		candidates = chain[1:]
+		first = 1
+	}
+
+	for i, cert := range candidates {
+		if tlsa.Verify(cert) == nil {
+			record.Matched = true
+			record.MatchedIndex = first + i
+			break
+		}
+	}
+
+	return record
+}
+
+// String formats r as it appears in a zone file
+func (r TLSARecord) String() string {
+	return fmt.Sprintf("%d %d %d %s", r.Usage, r.Selector, r.MatchingType, r.Data)
+}
+
+// report returns d as JSON fields and lines of plain output
+func (d *DANE) report(_ func(time.Time) string) (fields map[string]any, lines []string) {
+	records := make([]map[string]any, 0, len(d.Records))
+	for _, r := range d.Records {
+		record := map[string]any{
+			"usage":        r.Usage,
+			"selector":     r.Selector,
+			"matchingType": r.MatchingType,
+			"data":         r.Data,
+			"matched":      r.Matched,
+		}
+		if r.Matched {
+			record["matchedIndex"] = r.MatchedIndex
+		}
+		if r.Unverified {
+			record["unverified"] = true
+		}
+		records = append(records, record)
+	}
+
+	fields = map[string]any{
+		"name":          d.Name,
+		"records":       records,
+		"authenticated": d.Authenticated,
+		"valid":         d.Valid,
+	}
+
+	status := "valid"
+	switch {
+	case len(d.Records) == 0:
+		status = "no TLSA records"
+	case !d.Valid:
+		status = "INVALID, no TLSA record matches"
+	}
+
+	if len(d.Records) > 0 && !d.Authenticated {
+		status += ", not DNSSEC authenticated"
+	}
+	lines = append(lines, fmt.Sprintf("  DANE %s: %s", d.Name, status))
+
+	for _, r := range d.Records {
+		switch {
+		case r.Matched:
+			lines = append(lines, fmt.Sprintf("  TLSA %s\tmatches certificate %d", r, r.MatchedIndex))
+		case r.Unverified:
+			lines = append(lines, fmt.Sprintf("  TLSA %s\tNO MATCH, the chain failed PKIX validation", r))
+		default:
+			lines = append(lines, fmt.Sprintf("  TLSA %s\tNO MATCH", r))
+		}
+	}
+
+	return
+}
@@ -0,0 +1,182 @@
+package certificate_test
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/likexian/gokit/assert"
+	"github.com/mckern/spiry/internal/certificate"
+	"github.com/miekg/dns"
+)
+
+// serveDNS answers DNS queries on a loopback address with records,
+// marking answers as DNSSEC authenticated, and returns the address
+func serveDNS(t *testing.T, records ...dns.RR) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &dns.Server{PacketConn: conn, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(req)
+		resp.AuthenticatedData = true
+
+		q := req.Question[0]
+		for _, rr := range records {
+			if rr.Header().Rrtype == q.Qtype && dns.CanonicalName(rr.Header().Name) == dns.CanonicalName(q.Name) {
+				resp.Answer = append(resp.Answer, rr)
+			}
+		}
+
+		_ = w.WriteMsg(resp)
+	})}
+
+	started := make(chan struct{})
+	server.NotifyStartedFunc = func() { close(started) }
+	go func() { _ = server.ActivateAndServe() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+	<-started
+
+	return conn.LocalAddr().String()
+}
+
+// newTLSA returns a TLSA record at name matching cert's public key
+// by its SHA-256 digest, with the given usage
+func newTLSA(t *testing.T, name string, usage uint8, cert *x509.Certificate) *dns.TLSA {
+	t.Helper()
+
+	digest := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return &dns.TLSA{
+		Hdr:          dns.RR_Header{Name: dns.Fqdn(name), Rrtype: dns.TypeTLSA, Class: dns.ClassINET, Ttl: 300},
+		Usage:        usage,
+		Selector:     1,
+		MatchingType: 1,
+		Certificate:  hex.EncodeToString(digest[:]),
+	}
+}
+
+func TestDANE(t *testing.T) {
+	pki, stale := newTestPKI(t), newTestPKI(t)
+	addr := serveTLS(t, &tls.Config{Certificates: []tls.Certificate{pki.tlsCertificate()}})
+	_, port, _ := net.SplitHostPort(addr)
+	name := "_" + port + "._tcp.localhost"
+
+	roots := x509.NewCertPool()
+	roots.AddCert(pki.root.cert)
+
+	tests := []struct {
+		name    string
+		records []dns.RR
+		roots   *x509.CertPool
+		valid   bool
+		matched []bool
+	}{
+		{
+			name:    "DANE-EE",
+			records: []dns.RR{newTLSA(t, name, 3, pki.leaf.cert)},
+			valid:   true,
+			matched: []bool{true},
+		},
+		{
+			name:    "DANE-TA",
+			records: []dns.RR{newTLSA(t, name, 2, pki.intermediate.cert)},
+			valid:   true,
+			matched: []bool{true},
+		},
+		{
+			name:    "PKIX-EE",
+			records: []dns.RR{newTLSA(t, name, 1, pki.leaf.cert)},
+			roots:   roots,
+			valid:   true,
+			matched: []bool{true},
+		},
+		{
+			name:    "PKIX-TA",
+			records: []dns.RR{newTLSA(t, name, 0, pki.intermediate.cert)},
+			roots:   roots,
+			valid:   true,
+			matched: []bool{true},
+		},
+		{
+			name:    "PKIX-EE failing PKIX validation",
+			records: []dns.RR{newTLSA(t, name, 1, pki.leaf.cert)},
+			roots:   x509.NewCertPool(),
+			valid:   false,
+			matched: []bool{false},
+		},
+		{
+			name:    "stale rollover",
+			records: []dns.RR{newTLSA(t, name, 3, stale.leaf.cert), newTLSA(t, name, 3, pki.leaf.cert)},
+			valid:   true,
+			matched: []bool{false, true},
+		},
+		{
+			name:    "mismatch",
+			records: []dns.RR{newTLSA(t, name, 3, stale.leaf.cert), newTLSA(t, name, 3, pki.intermediate.cert)},
+			valid:   false,
+			matched: []bool{false, false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert, err := certificate.NewWithName("localhost", addr)
+			assert.Nil(t, err, "the address should parse")
+			cert.CheckDANE = true
+			cert.Resolver = serveDNS(t, tt.records...)
+			cert.Roots = tt.roots
+
+			d, err := cert.DANE()
+			assert.Nil(t, err, "the TLSA records should be looked up")
+			assert.Equal(t, d.Name, name+".")
+			assert.True(t, d.Authenticated, "the resolver's authentication should be reported")
+			assert.Equal(t, d.Valid, tt.valid)
+			for i, matched := range tt.matched {
+				assert.Equal(t, d.Records[i].Matched, matched)
+			}
+
+			fields, lines := certificate.ReportOf(d, rfc3339)
+			assert.Equal(t, fields["valid"], tt.valid, "the DANE check should be reported as a JSON field")
+			assert.Equal(t, strings.Contains(lines[0], "INVALID"), !tt.valid, "a mismatch should be called out in plain output")
+		})
+	}
+}
+
+func TestDANEWithoutRecords(t *testing.T) {
+	pki := newTestPKI(t)
+	addr := serveTLS(t, &tls.Config{Certificates: []tls.Certificate{pki.tlsCertificate()}})
+
+	cert, err := certificate.NewWithName("localhost", addr)
+	assert.Nil(t, err, "the address should parse")
+	cert.Resolver = serveDNS(t)
+
+	d, err := cert.DANE()
+	assert.Nil(t, err, "a name without TLSA records should not be an error")
+	assert.Equal(t, len(d.Records), 0)
+	assert.False(t, d.Valid, "a name without TLSA records should not be valid")
+}
+
+func TestDANEOverQUIC(t *testing.T) {
+	pki := newTestPKI(t)
+	addr := serveQUIC(t, &tls.Config{Certificates: []tls.Certificate{pki.tlsCertificate()}})
+	_, port, _ := net.SplitHostPort(addr)
+	name := "_" + port + "._udp.localhost"
+
+	cert, err := certificate.NewWithName("localhost", addr)
+	assert.Nil(t, err, "the address should parse")
+	cert.QUIC = true
+	cert.Resolver = serveDNS(t, newTLSA(t, name, 3, pki.leaf.cert))
+
+	d, err := cert.DANE()
+	assert.Nil(t, err, "the TLSA records should be looked up")
+	assert.Equal(t, d.Name, name+".", "the TLSA records of a QUIC server should be looked up under _udp")
+	assert.True(t, d.Valid, "the certificate served over QUIC should match")
+}
@@ -0,0 +1,141 @@
+package certificate
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// postgresSSLRequestCode is sent in place of a protocol version
+// to ask a PostgreSQL server to switch to TLS
+const postgresSSLRequestCode = 80877103
+
+// capability flags exchanged during a MySQL connection handshake
+const (
+	mysqlClientProtocol41       = 0x00000200
+	mysqlClientSSL              = 0x00000800
+	mysqlClientSecureConnection = 0x00008000
+)
+
+// startPostgres sends an SSLRequest to a PostgreSQL server,
+// which answers with a single byte before the TLS handshake begins
+func startPostgres(conn net.Conn, _ string) error {
+	request := make([]byte, 8)
+	binary.BigEndian.PutUint32(request[0:4], 8)
+	binary.BigEndian.PutUint32(request[4:8], postgresSSLRequestCode)
+
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+
+	reply := make([]byte, 1)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+
+	switch reply[0] {
+	case 'S':
+		return nil
+	case 'N':
+		return errors.New("PostgreSQL server does not accept TLS connections")
+	default:
+		return fmt.Errorf("unexpected reply to PostgreSQL SSLRequest: %q", reply[0])
+	}
+}
+
+// startMySQL reads the initial handshake from a MySQL server and
+// answers with an SSLRequest packet, the abbreviated handshake
+// response that tells the server to begin a TLS handshake
+func startMySQL(conn net.Conn, _ string) error {
+	payload, seq, err := readMySQLPacket(conn)
+	if err != nil {
+		return err
+	}
+
+	capabilities, err := parseMySQLHandshake(payload)
+	if err != nil {
+		return err
+	}
+
+	if capabilities&mysqlClientSSL == 0 {
+		return errors.New("MySQL server does not accept TLS connections")
+	}
+
+	request := make([]byte, 32)
+	binary.LittleEndian.PutUint32(request[0:4],
+		mysqlClientProtocol41|mysqlClientSSL|mysqlClientSecureConnection)
+	// maximum packet size, followed by the utf8_general_ci character set;
+	// the remaining 23 bytes are reserved and left empty
+	binary.LittleEndian.PutUint32(request[4:8], 1<<24)
+	request[8] = 0x21
+
+	return writeMySQLPacket(conn, seq+1, request)
+}
+
+// readMySQLPacket reads a single packet, returning its
+// payload and sequence number
+func readMySQLPacket(conn net.Conn) (payload []byte, seq byte, err error) {
+	header := make([]byte, 4)
+	if _, err = io.ReadFull(conn, header); err != nil {
+		return
+	}
+
+	length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+	seq = header[3]
+
+	payload = make([]byte, length)
+	_, err = io.ReadFull(conn, payload)
+	return
+}
+
+// writeMySQLPacket prefixes payload with a packet header and sends it
+func writeMySQLPacket(conn net.Conn, seq byte, payload []byte) error {
+	length := len(payload)
+	packet := append([]byte{byte(length), byte(length >> 8), byte(length >> 16), seq}, payload...)
+
+	_, err := conn.Write(packet)
+	return err
+}
+
+// parseMySQLHandshake returns the capability flags advertised
+// by a MySQL server in its initial handshake packet
+func parseMySQLHandshake(payload []byte) (capabilities uint32, err error) {
+	if len(payload) > 0 && payload[0] == 0xff {
+		// an error packet: a two byte error code, then a message
+		// that is prefixed by a SQL state marker and code
+		msg := payload[min(len(payload), 3):]
+		if len(msg) > 6 && msg[0] == '#' {
+			msg = msg[6:]
+		}
+		return 0, fmt.Errorf("MySQL server refused connection: %s", msg)
+	}
+
+	if len(payload) == 0 || payload[0] != 10 {
+		return 0, errors.New("unsupported MySQL handshake protocol")
+	}
+
+	// skip the protocol version and the NUL-terminated server version
+	end := bytes.IndexByte(payload[1:], 0)
+	if end < 0 {
+		return 0, errors.New("malformed MySQL handshake")
+	}
+
+	// skip the connection ID, first part of the auth plugin data,
+	// and a filler byte, to find the lower capability flags
+	pos := 1 + end + 1 + 4 + 8 + 1
+	if len(payload) < pos+2 {
+		return 0, errors.New("malformed MySQL handshake")
+	}
+	capabilities = uint32(binary.LittleEndian.Uint16(payload[pos : pos+2]))
+
+	// the upper capability flags follow the character set and status flags
+	if len(payload) >= pos+2+1+2+2 {
+		upper := binary.LittleEndian.Uint16(payload[pos+5 : pos+7])
+		capabilities |= uint32(upper) << 16
+	}
+
+	return capabilities, nil
+}
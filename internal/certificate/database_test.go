@@ -0,0 +1,103 @@
+package certificate_test
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/likexian/gokit/assert"
+	"github.com/mckern/spiry/internal/certificate"
+)
+
+// postgresDialogue answers an SSLRequest with reply
+func postgresDialogue(reply byte) dialogue {
+	return func(conn net.Conn) error {
+		request := make([]byte, 8)
+		if _, err := io.ReadFull(conn, request); err != nil {
+			return err
+		}
+
+		if binary.BigEndian.Uint32(request[4:]) != 80877103 {
+			return errors.New("not an SSLRequest")
+		}
+
+		if _, err := conn.Write([]byte{reply}); err != nil {
+			return err
+		}
+
+		if reply != 'S' {
+			return errors.New("TLS refused")
+		}
+		return nil
+	}
+}
+
+// mysqlDialogue sends an initial handshake advertising capabilities,
+// and waits for an SSLRequest packet in response
+func mysqlDialogue(capabilities uint32) dialogue {
+	return func(conn net.Conn) error {
+		payload := []byte{10}
+		payload = append(payload, "8.0.0-fake\x00"...)
+		payload = append(payload, 1, 0, 0, 0)
+		payload = append(payload, "abcdefgh\x00"...)
+		payload = binary.LittleEndian.AppendUint16(payload, uint16(capabilities))
+		payload = append(payload, 0x21, 2, 0)
+		payload = binary.LittleEndian.AppendUint16(payload, uint16(capabilities>>16))
+
+		length := len(payload)
+		packet := append([]byte{byte(length), byte(length >> 8), byte(length >> 16), 0}, payload...)
+		if _, err := conn.Write(packet); err != nil {
+			return err
+		}
+
+		request := make([]byte, 4+32)
+		if _, err := io.ReadFull(conn, request); err != nil {
+			return err
+		}
+
+		if binary.LittleEndian.Uint32(request[4:8])&0x800 == 0 {
+			return errors.New("not an SSLRequest")
+		}
+		return nil
+	}
+}
+
+func TestDatabaseTLS(t *testing.T) {
+	pki := newTestPKI(t)
+	config := &tls.Config{Certificates: []tls.Certificate{pki.tlsCertificate()}}
+
+	var databaseTests = []struct {
+		name    string
+		scheme  string
+		d       dialogue
+		wantErr bool
+	}{
+		{name: "PostgreSQL accepting TLS", scheme: "postgres", d: postgresDialogue('S')},
+		{name: "PostgreSQL refusing TLS", scheme: "postgresql", d: postgresDialogue('N'), wantErr: true},
+		{name: "MySQL accepting TLS", scheme: "mysql", d: mysqlDialogue(0x0000a200 | 0x800)},
+		{name: "MySQL without TLS", scheme: "mysql", d: mysqlDialogue(0x0000a200), wantErr: true},
+	}
+
+	for _, tt := range databaseTests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := serveStartTLS(t, config, tt.d)
+			_, port, _ := net.SplitHostPort(addr)
+
+			cert, err := certificate.New(tt.scheme + "://localhost:" + port)
+			assert.Nil(t, err, "a URL with a database scheme should parse")
+			assert.Equal(t, cert.StartTLS, tt.scheme, "the URL scheme should select the database protocol")
+
+			expiry, err := cert.Expiry()
+			if tt.wantErr {
+				assert.NotNil(t, err, "a server refusing TLS should raise an error")
+				return
+			}
+
+			assert.Nil(t, err, "a certificate should be retrieved from the database server")
+			assert.True(t, expiry.Equal(pki.leaf.cert.NotAfter), "the leaf certificate should be retrieved")
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package certificate
+
+import (
+	"crypto/dsa" //nolint:staticcheck // DSA keys are still found in the wild
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Details describes a certificate well enough to tell
+// which certificate it is, and who issued it
+type Details struct {
+	Subject            string
+	Issuer             string
+	SerialNumber       string
+	DNSNames           []string
+	IPAddresses        []string
+	EmailAddresses     []string
+	URIs               []string
+	NotBefore          time.Time
+	NotAfter           time.Time
+	KeyAlgorithm       string
+	KeySize            int
+	SignatureAlgorithm string
+	// Fingerprint is the SHA-256 digest of the DER-encoded certificate
+	Fingerprint string
+}
+
+// Details describes the leaf certificate presented by the server
+func (c *Certificate) Details() (*Details, error) {
+	if _, err := c.Expiry(); err != nil {
+		return nil, err
+	}
+
+	return DetailsOf(c.raw), nil
+}
+
+// DetailsOf describes cert
+func DetailsOf(cert *x509.Certificate) *Details {
+	d := &Details{
+		Subject:            cert.Subject.String(),
+		Issuer:             cert.Issuer.String(),
+		SerialNumber:       colonHex(cert.SerialNumber.Bytes()),
+		DNSNames:           cert.DNSNames,
+		EmailAddresses:     cert.EmailAddresses,
+		NotBefore:          cert.NotBefore,
+		NotAfter:           cert.NotAfter,
+		KeyAlgorithm:       cert.PublicKeyAlgorithm.String(),
+		KeySize:            keySize(cert.PublicKey),
+		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+	}
+
+	for _, ip := range cert.IPAddresses {
+		d.IPAddresses = append(d.IPAddresses, ip.String())
+	}
+
+	for _, uri := range cert.URIs {
+		d.URIs = append(d.URIs, uri.String())
+	}
+
+	sum := sha256.Sum256(cert.Raw)
+	d.Fingerprint = colonHex(sum[:])
+
+	return d
+}
+
+// report returns d as JSON fields and lines of plain output,
+// using format for any times
+func (d *Details) report(format func(time.Time) string) (fields map[string]any, lines []string) {
+	fields = map[string]any{
+		"subject":            d.Subject,
+		"issuer":             d.Issuer,
+		"serialNumber":       d.SerialNumber,
+		"dnsNames":           nonNil(d.DNSNames),
+		"ipAddresses":        nonNil(d.IPAddresses),
+		"emailAddresses":     nonNil(d.EmailAddresses),
+		"uris":               nonNil(d.URIs),
+		"notBefore":          format(d.NotBefore),
+		"notAfter":           format(d.NotAfter),
+		"keyAlgorithm":       d.KeyAlgorithm,
+		"keySize":            d.KeySize,
+		"signatureAlgorithm": d.SignatureAlgorithm,
+		"sha256Fingerprint":  d.Fingerprint,
+	}
+
+	line := func(label string, value string) {
+		if value != "" {
+			lines = append(lines, fmt.Sprintf("  %-20s %s", label+":", value))
+		}
+	}
+
+	line("subject", d.Subject)
+	line("issuer", d.Issuer)
+	line("serial number", d.SerialNumber)
+	line("DNS names", strings.Join(d.DNSNames, ", "))
+	line("IP addresses", strings.Join(d.IPAddresses, ", "))
+	line("email addresses", strings.Join(d.EmailAddresses, ", "))
+	line("URIs", strings.Join(d.URIs, ", "))
+	line("not before", format(d.NotBefore))
+	line("not after", format(d.NotAfter))
+	line("public key", fmt.Sprintf("%s %d bits", d.KeyAlgorithm, d.KeySize))
+	line("signature algorithm", d.SignatureAlgorithm)
+	line("SHA-256 fingerprint", d.Fingerprint)
+
+	return
+}
+
+// keySize returns the size of a public key in bits,
+// or zero for unknown key types
+func keySize(key any) int {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return k.N.BitLen()
+	case *ecdsa.PublicKey:
+		return k.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return len(k) * 8
+	case *dsa.PublicKey:
+		return k.P.BitLen()
+	default:
+		return 0
+	}
+}
+
+// colonHex formats b as colon-separated, upper case hex,
+// as openssl does for serial numbers and fingerprints
+func colonHex(b []byte) string {
+	parts := make([]string, len(b))
+	for i := range b {
+		parts[i] = fmt.Sprintf("%02X", b[i])
+	}
+
+	return strings.Join(parts, ":")
+}
+
+// nonNil returns s, or an empty slice if s is nil, so that
+// JSON output uses [] rather than null
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+
+	return s
+}
@@ -0,0 +1,45 @@
+package certificate_test
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/likexian/gokit/assert"
+	"github.com/mckern/spiry/internal/certificate"
+)
+
+func TestDetailsOf(t *testing.T) {
+	pki := newTestPKI(t)
+	d := certificate.DetailsOf(pki.leaf.cert)
+
+	sum := sha256.Sum256(pki.leaf.cert.Raw)
+	assert.Equal(t, strings.ReplaceAll(d.Fingerprint, ":", ""), fmt.Sprintf("%X", sum[:]),
+		"the fingerprint should be the SHA-256 digest of the certificate")
+	assert.Equal(t, strings.ReplaceAll(d.SerialNumber, ":", ""), fmt.Sprintf("%X", pki.leaf.cert.SerialNumber.Bytes()),
+		"the serial number should be formatted as hex")
+	assert.Equal(t, d.Issuer, "CN=spiry test intermediate", "the issuer should be described")
+	assert.Equal(t, d.DNSNames, []string{"localhost"}, "DNS names should be described")
+	assert.Equal(t, d.IPAddresses, []string{"127.0.0.1"}, "IP addresses should be described")
+	assert.Equal(t, d.KeyAlgorithm, "ECDSA", "the public key algorithm should be described")
+	assert.Equal(t, d.KeySize, 256, "the public key size should be described")
+	assert.Equal(t, d.SignatureAlgorithm, "ECDSA-SHA256", "the signature algorithm should be described")
+}
+
+func TestDetailsReport(t *testing.T) {
+	pki := newTestPKI(t)
+	addr := serveTLS(t, &tls.Config{Certificates: []tls.Certificate{pki.tlsCertificate()}})
+	format := func(t time.Time) string { return t.Format(time.RFC3339) }
+
+	cert, _ := certificate.New(addr)
+	d, err := cert.Details()
+	assert.Nil(t, err, "a certificate should be retrieved")
+	assert.Equal(t, d.Subject, "CN=localhost", "the leaf certificate should be described")
+
+	fields, lines := certificate.ReportOf(d, format)
+	assert.Equal(t, fields["sha256Fingerprint"], d.Fingerprint, "details should be reported as a JSON field")
+	assert.Contains(t, strings.Join(lines, "\n"), d.Fingerprint, "details should be reported in plain output")
+}
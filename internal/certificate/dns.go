@@ -0,0 +1,69 @@
+package certificate
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// resolvConf is where the system's DNS resolvers are configured
+const resolvConf = "/etc/resolv.conf"
+
+// resolver returns the address of the DNS resolver to query:
+// Resolver if it is set, or the first system resolver otherwise
+func (c *Certificate) resolver() (string, error) {
+	if c.Resolver != "" {
+		if _, _, err := net.SplitHostPort(c.Resolver); err != nil {
+			return net.JoinHostPort(c.Resolver, "53"), nil
+		}
+		return c.Resolver, nil
+	}
+
+	config, err := dns.ClientConfigFromFile(resolvConf)
+	if err != nil {
+		return "", fmt.Errorf("unable to find a DNS resolver: %w", err)
+	}
+
+	if len(config.Servers) == 0 {
+		return "", fmt.Errorf("no DNS resolvers found in %v", resolvConf)
+	}
+
+	return net.JoinHostPort(config.Servers[0], config.Port), nil
+}
+
+// lookup queries the resolver for records of qtype at name, asking for
+// DNSSEC validation so that the response says whether it is authenticated
+func (c *Certificate) lookup(name string, qtype uint16) (*dns.Msg, error) {
+	server, err := c.resolver()
+	if err != nil {
+		return nil, err
+	}
+
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(name), qtype)
+	msg.SetEdns0(4096, true)
+	msg.AuthenticatedData = true
+
+	client := &dns.Client{Timeout: c.Network.connectTimeout() + c.Network.handshakeTimeout()}
+
+	slog.Debug("querying DNS", "name", name, "type", dns.TypeToString[qtype], "resolver", server)
+	resp, _, err := client.Exchange(msg, server)
+	if err == nil && resp.Truncated {
+		client.Net = "tcp"
+		resp, _, err = client.Exchange(msg, server)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.Rcode {
+	case dns.RcodeSuccess, dns.RcodeNameError:
+		return resp, nil
+	default:
+		return nil, errors.New(dns.RcodeToString[resp.Rcode])
+	}
+}
@@ -0,0 +1,53 @@
+package certificate
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// SetLookupIPAddr replaces name resolution for the duration of a test
+func SetLookupIPAddr(lookup func(ctx context.Context, host string) ([]net.IPAddr, error)) (restore func()) {
+	original := lookupIPAddr
+	lookupIPAddr = lookup
+	return func() { lookupIPAddr = original }
+}
+
+// RenewalOf exposes renewalOf, so that renewal windows can be
+// checked at any point in a certificate's lifetime
+func RenewalOf(notBefore time.Time, notAfter time.Time, threshold float64, now time.Time) *Renewal {
+	return renewalOf(notBefore, notAfter, threshold, now)
+}
+
+// reporter is a section of Certificate.Report
+type reporter interface {
+	report(format func(time.Time) string) (fields map[string]any, lines []string)
+}
+
+// ReportOf exposes the report of a single section of Certificate.Report
+func ReportOf(r reporter, format func(time.Time) string) (fields map[string]any, lines []string) {
+	return r.report(format)
+}
+
+// ReportBackends exposes the section of Certificate.Report
+// describing the certificate served at every address
+var ReportBackends = (*Certificate).reportBackends
+
+// ReportClient exposes the section of Certificate.Report
+// describing the client certificate
+var ReportClient = (*Certificate).reportClient
+
+// ReportQUIC exposes the section of Certificate.Report
+// comparing the certificates served over TCP and QUIC
+var ReportQUIC = (*Certificate).reportQUIC
+
+// ReportKeyTypes exposes the section of Certificate.Report
+// describing the certificate served for each key type
+var ReportKeyTypes = (*Certificate).reportKeyTypes
+
+// ReportSANs exposes the section of Certificate.Report
+// describing every subject alternative name
+var ReportSANs = (*Certificate).reportSANs
+
+// ProxyFromEnvironment exposes proxyFromEnvironment
+var ProxyFromEnvironment = proxyFromEnvironment
@@ -0,0 +1,138 @@
+package certificate
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// keyTypes are the kinds of certificate a server may choose between,
+// in the order they are requested
+var keyTypes = []string{"rsa", "ecdsa"}
+
+// tlsVersions maps the TLS versions accepted on the command line
+// to their crypto/tls constants
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// KeyTypeCertificate is the outcome of asking the server
+// for a certificate with one kind of public key
+type KeyTypeCertificate struct {
+	KeyType string
+	Chain   []*x509.Certificate
+	Err     error
+	// SameAs names the earlier key type that was answered with
+	// the same leaf certificate, if any
+	SameAs string
+}
+
+// KeyTypeCertificates returns the outcome of asking the server for an
+// RSA and an ECDSA certificate in turn, when KeyTypes is set
+func (c *Certificate) KeyTypeCertificates() ([]KeyTypeCertificate, error) {
+	if _, err := c.Expiry(); err != nil {
+		return nil, err
+	}
+
+	return c.keyTypes, nil
+}
+
+// retrieveKeyTypes asks the server for a certificate of every key type.
+// A server that has no certificate of a given type fails the handshake,
+// which is reported rather than treated as an error.
+func (c *Certificate) retrieveKeyTypes() {
+	c.keyTypes = make([]KeyTypeCertificate, 0, len(keyTypes))
+	seen := map[[sha256.Size]byte]string{}
+
+	for _, keyType := range keyTypes {
+		k := KeyTypeCertificate{KeyType: keyType}
+
+		tlsConfig, err := c.keyTypeConfig(keyType)
+		if err == nil {
+			var state tls.ConnectionState
+			state, err = c.getState(c.addr, tlsConfig)
+			k.Chain = state.PeerCertificates
+		}
+
+		if err != nil {
+			slog.Debug("unable to retrieve certificate", "keyType", keyType, "error", err)
+			k.Err = err
+		} else {
+			sum := sha256.Sum256(k.Chain[0].Raw)
+			if earlier, ok := seen[sum]; ok {
+				k.SameAs = earlier
+			} else {
+				seen[sum] = keyType
+			}
+		}
+
+		c.keyTypes = append(c.keyTypes, k)
+	}
+}
+
+// keyTypeConfig returns a TLS configuration that only a certificate
+// of keyType can satisfy. TLS 1.3 leaves the choice of certificate to
+// signature algorithms that crypto/tls does not let clients restrict,
+// so the handshake is limited to TLS 1.2 cipher suites that require
+// a key of the given type.
+func (c *Certificate) keyTypeConfig(keyType string) (*tls.Config, error) {
+	if c.TLSVersion == tls.VersionTLS13 {
+		return nil, errors.New("RSA and ECDSA certificates can only be requested separately with TLS 1.2 or earlier")
+	}
+
+	tlsConfig := c.tlsConfig()
+	if tlsConfig.MaxVersion == 0 {
+		tlsConfig.MaxVersion = tls.VersionTLS12
+	}
+
+	kind := "_" + strings.ToUpper(keyType) + "_"
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if strings.Contains(suite.Name, kind) {
+			tlsConfig.CipherSuites = append(tlsConfig.CipherSuites, suite.ID)
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// reportKeyTypes describes the certificate served for every key type,
+// calling out the ones that are the same as another
+func (c *Certificate) reportKeyTypes(format func(time.Time) string) (certs []map[string]any, lines []string) {
+	for _, k := range c.keyTypes {
+		if k.Err != nil {
+			certs = append(certs, map[string]any{
+				"keyType": k.KeyType,
+				"error":   k.Err.Error(),
+			})
+			lines = append(lines, fmt.Sprintf("  %s\terror=%q", k.KeyType, k.Err))
+			continue
+		}
+
+		d := DetailsOf(k.Chain[0])
+		expiry := format(c.chainExpiry(k.Chain))
+		certs = append(certs, map[string]any{
+			"keyType":           k.KeyType,
+			"expiry":            expiry,
+			"serialNumber":      d.SerialNumber,
+			"sha256Fingerprint": d.Fingerprint,
+			"keyAlgorithm":      d.KeyAlgorithm,
+			"sameAs":            k.SameAs,
+		})
+
+		line := fmt.Sprintf("  %s\texpiry=%s\tsha256=%s\tkey=%s", k.KeyType, expiry, d.Fingerprint, d.KeyAlgorithm)
+		if k.SameAs != "" {
+			line += "\tsame as " + k.SameAs
+		}
+		lines = append(lines, line)
+	}
+
+	return
+}
@@ -0,0 +1,122 @@
+package certificate_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/likexian/gokit/assert"
+	"github.com/mckern/spiry/internal/certificate"
+)
+
+// newRSALeaf issues an RSA leaf certificate from the test PKI's
+// intermediate, expiring after days
+func newRSALeaf(t *testing.T, pki *testPKI, days int) tls.Certificate {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().Truncate(time.Second)
+	leaf := newTestCertWithKey(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "localhost"},
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:   now.Add(-time.Hour),
+		NotAfter:    now.AddDate(0, 0, days),
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, &pki.intermediate, key)
+
+	return tls.Certificate{
+		Certificate: [][]byte{leaf.cert.Raw, pki.intermediate.cert.Raw},
+		PrivateKey:  leaf.key,
+		Leaf:        leaf.cert,
+	}
+}
+
+func TestKeyTypes(t *testing.T) {
+	pki := newTestPKI(t)
+	rsaLeaf := newRSALeaf(t, pki, 7)
+	addr := serveTLS(t, &tls.Config{Certificates: []tls.Certificate{pki.tlsCertificate(), rsaLeaf}})
+
+	cert, err := certificate.New(addr)
+	assert.Nil(t, err, "a host:port pair should parse")
+	cert.KeyTypes = true
+
+	expiry, err := cert.Expiry()
+	assert.Nil(t, err, "the certificate should be retrieved")
+	assert.Equal(t, expiry, rsaLeaf.Leaf.NotAfter, "the earlier RSA certificate's expiry should be reported")
+
+	certs, err := cert.KeyTypeCertificates()
+	assert.Nil(t, err, "the certificate should be retrieved")
+	assert.Equal(t, len(certs), 2, "both key types should be reported")
+	assert.Equal(t, certs[0].KeyType, "rsa")
+	assert.Equal(t, certs[0].Chain[0].PublicKeyAlgorithm, x509.RSA, "an RSA certificate should be served")
+	assert.Equal(t, certs[1].KeyType, "ecdsa")
+	assert.Equal(t, certs[1].Chain[0].PublicKeyAlgorithm, x509.ECDSA, "an ECDSA certificate should be served")
+	assert.Equal(t, certs[1].SameAs, "", "distinct certificates should not be called out")
+
+	reported, lines := certificate.ReportKeyTypes(cert, rfc3339)
+	assert.Equal(t, len(reported), 2, "every key type should be reported as a JSON field")
+	assert.Equal(t, len(lines), 2, "every key type should be reported in plain output")
+}
+
+func TestKeyTypesSingleCertificate(t *testing.T) {
+	pki := newTestPKI(t)
+	addr := serveTLS(t, &tls.Config{Certificates: []tls.Certificate{pki.tlsCertificate()}})
+
+	cert, err := certificate.New(addr)
+	assert.Nil(t, err, "a host:port pair should parse")
+	cert.KeyTypes = true
+
+	expiry, err := cert.Expiry()
+	assert.Nil(t, err, "a server without an RSA certificate should not fail the check")
+	assert.Equal(t, expiry, pki.leaf.cert.NotAfter, "the ECDSA certificate's expiry should be reported")
+
+	certs, err := cert.KeyTypeCertificates()
+	assert.Nil(t, err, "the certificate should be retrieved")
+	assert.NotNil(t, certs[0].Err, "a missing RSA certificate should be reported")
+	assert.Nil(t, certs[1].Err, "the ECDSA certificate should be retrieved")
+}
+
+func TestKeyTypesTLS13(t *testing.T) {
+	pki := newTestPKI(t)
+	addr := serveTLS(t, &tls.Config{Certificates: []tls.Certificate{pki.tlsCertificate()}})
+
+	cert, err := certificate.New(addr)
+	assert.Nil(t, err, "a host:port pair should parse")
+	cert.KeyTypes = true
+	cert.TLSVersion = tls.VersionTLS13
+
+	_, err = cert.Expiry()
+	assert.Nil(t, err, "the certificate should be retrieved with TLS 1.3")
+
+	certs, err := cert.KeyTypeCertificates()
+	assert.Nil(t, err, "the certificate should be retrieved")
+	for _, k := range certs {
+		assert.NotNil(t, k.Err, "key types cannot be told apart with TLS 1.3")
+	}
+}
+
+func TestTLSVersion(t *testing.T) {
+	pki := newTestPKI(t)
+	addr := serveTLS(t, &tls.Config{
+		Certificates: []tls.Certificate{pki.tlsCertificate()},
+		MinVersion:   tls.VersionTLS13,
+	})
+
+	cert, err := certificate.New(addr)
+	assert.Nil(t, err, "a host:port pair should parse")
+	cert.TLSVersion = tls.VersionTLS12
+
+	_, err = cert.Expiry()
+	assert.NotNil(t, err, "a pinned TLS version the server refuses should fail")
+}
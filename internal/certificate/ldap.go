@@ -0,0 +1,134 @@
+package certificate
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// ldapStartTLSRequest is a complete LDAPMessage carrying the StartTLS
+// extended operation (RFC 4511, section 4.14.1) as message ID 1
+var ldapStartTLSRequest = append([]byte{
+	0x30, 0x1d, // LDAPMessage SEQUENCE
+	0x02, 0x01, 0x01, // messageID INTEGER 1
+	0x77, 0x18, // [APPLICATION 23] ExtendedRequest
+	0x80, 0x16, // [0] requestName
+}, "1.3.6.1.4.1.1466.20037"...)
+
+// BER tags found in an LDAP StartTLS exchange
+const (
+	berTagSequence         = 0x30
+	berTagEnumerated       = 0x0a
+	berTagExtendedResponse = 0x78 // [APPLICATION 24], constructed
+)
+
+// startLDAP sends the StartTLS extended operation to an LDAP server
+func startLDAP(conn net.Conn, _ string) error {
+	if _, err := conn.Write(ldapStartTLSRequest); err != nil {
+		return err
+	}
+
+	tag, message, err := readBER(bufio.NewReader(conn))
+	if err != nil {
+		return err
+	}
+	if tag != berTagSequence {
+		return fmt.Errorf("unexpected LDAP response tag %#x", tag)
+	}
+
+	// skip the message ID to find the protocol operation
+	_, _, rest, err := nextBER(message)
+	if err != nil {
+		return err
+	}
+
+	tag, op, _, err := nextBER(rest)
+	if err != nil {
+		return err
+	}
+	if tag != berTagExtendedResponse {
+		return fmt.Errorf("unexpected LDAP protocol operation %#x", tag)
+	}
+
+	tag, code, rest, err := nextBER(op)
+	if err != nil {
+		return err
+	}
+	if tag != berTagEnumerated || len(code) != 1 {
+		return errors.New("malformed LDAP extended response")
+	}
+
+	if code[0] != 0 {
+		// the matched DN is followed by a diagnostic message
+		_, _, rest, _ = nextBER(rest)
+		_, diagnostic, _, _ := nextBER(rest)
+		return fmt.Errorf("LDAP server refused StartTLS with result code %d: %q", code[0], diagnostic)
+	}
+
+	return nil
+}
+
+// readBER reads a single BER element from r, returning its
+// identifier octet and contents. Only low tag numbers and definite
+// lengths are supported, which is all that LDAP uses.
+func readBER(r io.ByteReader) (tag byte, contents []byte, err error) {
+	if tag, err = r.ReadByte(); err != nil {
+		return
+	}
+
+	first, err := r.ReadByte()
+	if err != nil {
+		return
+	}
+
+	length := int(first)
+	if first&0x80 != 0 {
+		octets := int(first & 0x7f)
+		if octets == 0 || octets > 4 {
+			return tag, nil, errors.New("unsupported BER length encoding")
+		}
+
+		length = 0
+		for range octets {
+			b, err := r.ReadByte()
+			if err != nil {
+				return tag, nil, err
+			}
+			length = length<<8 | int(b)
+		}
+	}
+
+	contents = make([]byte, length)
+	for i := range contents {
+		if contents[i], err = r.ReadByte(); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// nextBER splits the first BER element from b, returning its
+// identifier octet and contents, and the remainder of b
+func nextBER(b []byte) (tag byte, contents []byte, rest []byte, err error) {
+	r := &byteReader{b: b}
+	tag, contents, err = readBER(r)
+	return tag, contents, r.b, err
+}
+
+// byteReader is an io.ByteReader that consumes a byte slice
+type byteReader struct {
+	b []byte
+}
+
+func (r *byteReader) ReadByte() (byte, error) {
+	if len(r.b) == 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	b := r.b[0]
+	r.b = r.b[1:]
+	return b, nil
+}
@@ -0,0 +1,73 @@
+package certificate_test
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/likexian/gokit/assert"
+	"github.com/mckern/spiry/internal/certificate"
+)
+
+// ldapDialogue reads a StartTLS extended request, and answers with an
+// extended response carrying resultCode, using the non-minimal BER
+// lengths some LDAP servers are fond of
+func ldapDialogue(resultCode byte) dialogue {
+	return func(conn net.Conn) error {
+		request := make([]byte, 31)
+		if _, err := io.ReadFull(conn, request); err != nil {
+			return err
+		}
+
+		diagnostic := []byte("nope")
+		if resultCode == 0 {
+			diagnostic = nil
+		}
+
+		op := []byte{0x0a, 0x01, resultCode, 0x04, 0x00, 0x04, byte(len(diagnostic))}
+		op = append(op, diagnostic...)
+
+		message := []byte{0x02, 0x01, 0x01, 0x78, 0x84, 0, 0, 0, byte(len(op))}
+		message = append(message, op...)
+
+		response := []byte{0x30, 0x84, 0, 0, 0, byte(len(message))}
+		response = append(response, message...)
+
+		if _, err := conn.Write(response); err != nil {
+			return err
+		}
+
+		if resultCode != 0 {
+			return io.EOF
+		}
+		return nil
+	}
+}
+
+func TestLDAPStartTLS(t *testing.T) {
+	pki := newTestPKI(t)
+	config := &tls.Config{Certificates: []tls.Certificate{pki.tlsCertificate()}}
+
+	addr := serveStartTLS(t, config, ldapDialogue(0))
+	_, port, _ := net.SplitHostPort(addr)
+
+	cert, err := certificate.New("ldap://localhost:" + port)
+	assert.Nil(t, err, "an ldap:// URL should parse")
+	assert.Equal(t, cert.StartTLS, "ldap", "the URL scheme should select LDAP StartTLS")
+
+	expiry, err := cert.Expiry()
+	assert.Nil(t, err, "a certificate should be retrieved after StartTLS")
+	assert.True(t, expiry.Equal(pki.leaf.cert.NotAfter), "the leaf certificate should be retrieved")
+}
+
+func TestLDAPStartTLSRefused(t *testing.T) {
+	// resultCode 2 is protocolError
+	addr := serveStartTLS(t, &tls.Config{}, ldapDialogue(2))
+
+	cert, _ := certificate.New(addr)
+	cert.StartTLS = "ldap"
+	_, err := cert.Expiry()
+	assert.NotNil(t, err, "a refused StartTLS operation should raise an error")
+	assert.Contains(t, err.Error(), "nope", "the server's diagnostic message should be reported")
+}
@@ -0,0 +1,28 @@
+package certificate
+
+import (
+	"time"
+
+	"github.com/mckern/spiry/internal/lint"
+)
+
+// Findings checks the leaf certificate with Lints, or with every
+// registered lint if Lints is nil, skipping any named in SkipLints
+func (c *Certificate) Findings() ([]lint.Finding, error) {
+	if c.findings != nil {
+		return c.findings, nil
+	}
+
+	if _, err := c.Expiry(); err != nil {
+		return nil, err
+	}
+
+	lints := c.Lints
+	if lints == nil {
+		lints = lint.Registered()
+	}
+
+	ctx := lint.Context{Now: time.Now(), OCSPStapled: c.session.OCSPStapled}
+	c.findings = lint.Run(c.raw, ctx, lints, c.SkipLints...)
+	return c.findings, nil
+}
@@ -0,0 +1,58 @@
+package certificate_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/likexian/gokit/assert"
+	"github.com/mckern/spiry/internal/certificate"
+	"github.com/mckern/spiry/internal/lint"
+)
+
+func TestFindings(t *testing.T) {
+	pki := newTestPKI(t)
+	addr := serveTLS(t, &tls.Config{Certificates: []tls.Certificate{pki.tlsCertificate()}})
+
+	cert, err := certificate.New(addr)
+	assert.Nil(t, err, "a host:port pair should parse")
+	cert.Lint = true
+
+	findings, err := cert.Findings()
+	assert.Nil(t, err, "the certificate should be retrieved")
+	assert.Equal(t, len(findings), 0, "a well-formed certificate should have no findings")
+}
+
+func TestFindingsLongLived(t *testing.T) {
+	pki := newTestPKI(t)
+	now := time.Now().Truncate(time.Second)
+	leaf := newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "localhost"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:   now.Add(-time.Hour),
+		NotAfter:    now.AddDate(2, 0, 0),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+	}, &pki.intermediate)
+
+	cert, err := certificate.New(serveLeaf(t, pki, leaf))
+	assert.Nil(t, err, "a host:port pair should parse")
+	cert.Lint = true
+
+	findings, err := cert.Findings()
+	assert.Nil(t, err, "the certificate should be retrieved")
+	assert.Equal(t, len(findings), 2, "every problem should be found")
+	assert.Equal(t, findings[0].Lint, "lifetime-too-long")
+	assert.Equal(t, findings[0].Severity, lint.SeverityWarning, "a long lifetime should only be a warning")
+	assert.Equal(t, findings[1].Lint, "missing-server-auth")
+
+	cert, err = certificate.New(serveLeaf(t, pki, leaf))
+	assert.Nil(t, err, "a host:port pair should parse")
+	cert.SkipLints = []string{"missing-server-auth"}
+
+	findings, err = cert.Findings()
+	assert.Nil(t, err, "the certificate should be retrieved")
+	assert.Equal(t, len(findings), 1, "skipped lints should not be run")
+}
@@ -0,0 +1,220 @@
+package certificate
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// kinds of subject alternative name that can be checked
+const (
+	SANTypeDNS = "DNS"
+	SANTypeIP  = "IP"
+)
+
+// Coverage is the outcome of checking that a certificate
+// is valid for the name it was requested for
+type Coverage struct {
+	Name    string
+	Covered bool
+	// MatchedBy is the subject alternative name that covers Name,
+	// e.g. "DNS:*.example.com"
+	MatchedBy string
+}
+
+// maxSANChecks is how many subject alternative names are
+// checked at a time when CheckSANs is set
+const maxSANChecks = 8
+
+// SAN is a subject alternative name of the leaf certificate, along
+// with the outcome of checking the certificate served for it when
+// CheckSANs is set
+type SAN struct {
+	Type  string
+	Value string
+	// Checked is set when the certificate served for the name was
+	// retrieved; wildcard names cannot be checked.
+	Checked bool
+	Chain   []*x509.Certificate
+	Err     error
+	// Covered is set when the certificate served for the name is
+	// valid for it, and Same when it is the certificate requested.
+	Covered bool
+	Same    bool
+}
+
+// Uncovered reports whether the certificate served for the name was
+// retrieved, but isn't valid for it. A different certificate that is
+// valid for the name, as load balanced deployments commonly serve,
+// isn't a failure.
+func (s SAN) Uncovered() bool {
+	return s.Checked && s.Err == nil && !s.Covered
+}
+
+// NameCoverage checks that the leaf certificate has a subject alternative
+// name matching the requested name, whether exactly or by wildcard.
+// Common names are not considered, as no current client does.
+func (c *Certificate) NameCoverage() (*Coverage, error) {
+	if _, err := c.Expiry(); err != nil {
+		return nil, err
+	}
+
+	name := c.Name()
+	return &Coverage{Name: name, Covered: c.raw.VerifyHostname(name) == nil, MatchedBy: matchSAN(c.raw, name)}, nil
+}
+
+// SANs returns every DNS and IP subject alternative name of the leaf
+// certificate. When CheckSANs is set, the certificate served for each
+// name on the same port is retrieved and checked as well.
+func (c *Certificate) SANs() ([]SAN, error) {
+	if _, err := c.Expiry(); err != nil {
+		return nil, err
+	}
+
+	if c.sans != nil {
+		return c.sans, nil
+	}
+
+	sans := make([]SAN, 0, len(c.raw.DNSNames)+len(c.raw.IPAddresses))
+	for _, name := range c.raw.DNSNames {
+		sans = append(sans, SAN{Type: SANTypeDNS, Value: name})
+	}
+	for _, ip := range c.raw.IPAddresses {
+		sans = append(sans, SAN{Type: SANTypeIP, Value: ip.String()})
+	}
+
+	if c.CheckSANs {
+		c.checkSANs(sans)
+	}
+
+	c.sans = sans
+	return c.sans, nil
+}
+
+// checkSANs retrieves the certificate served for every name in sans
+// on the same port, except for wildcards, which cannot be dialed.
+// At most maxSANChecks names are checked at a time.
+func (c *Certificate) checkSANs(sans []SAN) {
+	_, port, _ := net.SplitHostPort(c.addr)
+	leaf := sha256.Sum256(c.raw.Raw)
+
+	sem := make(chan struct{}, maxSANChecks)
+	var wg sync.WaitGroup
+	for i := range sans {
+		san := &sans[i]
+		if strings.HasPrefix(san.Value, "*.") {
+			continue
+		}
+
+		wg.Go(func() {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			check := &Certificate{
+				addr:              net.JoinHostPort(san.Value, port),
+				StartTLS:          c.StartTLS,
+				QUIC:              c.QUIC,
+				TLSVersion:        c.TLSVersion,
+				ALPN:              c.ALPN,
+				Network:           c.Network,
+				ClientCertificate: c.ClientCertificate,
+			}
+
+			san.Checked = true
+			san.Chain, san.Err = check.getChain(check.addr)
+			if san.Err == nil {
+				san.Covered = san.Chain[0].VerifyHostname(san.Value) == nil
+				san.Same = sha256.Sum256(san.Chain[0].Raw) == leaf
+			}
+		})
+	}
+	wg.Wait()
+}
+
+// matchSAN returns the subject alternative name of cert that covers
+// name, formatted as openssl does, or "" if none of them do
+func matchSAN(cert *x509.Certificate, name string) string {
+	if ip := net.ParseIP(strings.Trim(name, "[]")); ip != nil {
+		for _, candidate := range cert.IPAddresses {
+			if candidate.Equal(ip) {
+				return SANTypeIP + ":" + candidate.String()
+			}
+		}
+
+		return ""
+	}
+
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	for _, candidate := range cert.DNSNames {
+		pattern := strings.ToLower(candidate)
+		if pattern == name {
+			return SANTypeDNS + ":" + candidate
+		}
+
+		// a wildcard covers exactly one label, in the leftmost position
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			if label, rest, found := strings.Cut(name, "."); found && label != "" && rest == suffix {
+				return SANTypeDNS + ":" + candidate
+			}
+		}
+	}
+
+	return ""
+}
+
+// report returns cov as JSON fields and lines of plain output
+func (cov *Coverage) report() (fields map[string]any, lines []string) {
+	fields = map[string]any{
+		"name":      cov.Name,
+		"covered":   cov.Covered,
+		"matchedBy": cov.MatchedBy,
+	}
+
+	if cov.Covered {
+		lines = append(lines, fmt.Sprintf("  name %s covered by %s", cov.Name, cov.MatchedBy))
+	} else {
+		lines = append(lines, fmt.Sprintf("  name %s NOT COVERED by any subject alternative name", cov.Name))
+	}
+
+	return
+}
+
+// reportSANs describes every subject alternative name, and the
+// certificate served for each of them if they were checked
+func (c *Certificate) reportSANs(format func(time.Time) string) (sans []map[string]any, lines []string) {
+	for _, san := range c.sans {
+		fields := map[string]any{"type": san.Type, "value": san.Value}
+		line := fmt.Sprintf("  %s:%s", san.Type, san.Value)
+
+		switch {
+		case !san.Checked:
+		case san.Err != nil:
+			fields["error"] = san.Err.Error()
+			line += fmt.Sprintf("\terror=%q", san.Err)
+		default:
+			d := DetailsOf(san.Chain[0])
+			expiry := format(c.chainExpiry(san.Chain))
+			fields["expiry"] = expiry
+			fields["sha256Fingerprint"] = d.Fingerprint
+			fields["covered"] = san.Covered
+			fields["same"] = san.Same
+
+			line += fmt.Sprintf("\texpiry=%s\tsha256=%s", expiry, d.Fingerprint)
+			if !san.Covered {
+				line += "\tNOT COVERED"
+			}
+			if !san.Same {
+				line += "\tDIFFERS"
+			}
+		}
+
+		sans = append(sans, fields)
+		lines = append(lines, line)
+	}
+
+	return
+}
@@ -0,0 +1,143 @@
+package certificate_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/likexian/gokit/assert"
+	"github.com/mckern/spiry/internal/certificate"
+)
+
+func TestNameCoverage(t *testing.T) {
+	pki := newTestPKI(t)
+	now := time.Now().Truncate(time.Second)
+	wildcard := newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "*.example.com"},
+		DNSNames:    []string{"*.example.com"},
+		NotBefore:   now.Add(-time.Hour),
+		NotAfter:    now.AddDate(0, 0, 90),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, &pki.intermediate)
+
+	addr := serveTLS(t, &tls.Config{Certificates: []tls.Certificate{pki.tlsCertificate()}})
+	wildcardAddr := serveLeaf(t, pki, wildcard)
+
+	tests := []struct {
+		name      string
+		addr      string
+		covered   bool
+		matchedBy string
+	}{
+		{name: "", addr: addr, covered: true, matchedBy: "IP:127.0.0.1"},
+		{name: "localhost", addr: addr, covered: true, matchedBy: "DNS:localhost"},
+		{name: "www.example.com", addr: addr, covered: false},
+		{name: "www.example.com", addr: wildcardAddr, covered: true, matchedBy: "DNS:*.example.com"},
+		{name: "WWW.Example.COM", addr: wildcardAddr, covered: true, matchedBy: "DNS:*.example.com"},
+		{name: "a.b.example.com", addr: wildcardAddr, covered: false},
+		{name: "example.com", addr: wildcardAddr, covered: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert, err := certificate.New(tt.addr)
+			if tt.name != "" {
+				cert, err = certificate.NewWithName(tt.name, tt.addr)
+			}
+			assert.Nil(t, err, "the address should parse")
+
+			coverage, err := cert.NameCoverage()
+			assert.Nil(t, err, "the certificate should be retrieved")
+			assert.Equal(t, coverage.Covered, tt.covered)
+			assert.Equal(t, coverage.MatchedBy, tt.matchedBy)
+		})
+	}
+}
+
+func TestCheckSANs(t *testing.T) {
+	pki, other := newTestPKI(t), newTestPKI(t)
+	addr := serveTLS(t, &tls.Config{
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			// serve another certificate to anyone asking for localhost
+			if hello.ServerName == "localhost" {
+				another := other.tlsCertificate()
+				return &another, nil
+			}
+			current := pki.tlsCertificate()
+			return &current, nil
+		},
+	})
+	_, port, _ := net.SplitHostPort(addr)
+
+	cert, err := certificate.New(addr)
+	assert.Nil(t, err, "a host:port pair should parse")
+	cert.ListSANs = true
+	cert.CheckSANs = true
+	cert.Network.Resolve = map[string][]string{"localhost:" + port: {addr}}
+
+	sans, err := cert.SANs()
+	assert.Nil(t, err, "the certificate should be retrieved")
+	assert.Equal(t, len(sans), 2, "every subject alternative name should be listed")
+
+	assert.Equal(t, sans[0].Type, certificate.SANTypeDNS)
+	assert.Equal(t, sans[0].Value, "localhost")
+	assert.True(t, sans[0].Checked, "the certificate served for localhost should be checked")
+	assert.Nil(t, sans[0].Err, "the certificate served for localhost should be retrieved")
+	assert.True(t, sans[0].Covered, "the certificate served for localhost should cover it")
+	assert.False(t, sans[0].Same, "a different certificate served for localhost should be called out")
+	assert.False(t, sans[0].Uncovered(), "a different certificate valid for localhost should pass the check")
+
+	assert.Equal(t, sans[1].Type, certificate.SANTypeIP)
+	assert.True(t, sans[1].Covered, "the certificate served for 127.0.0.1 should cover it")
+	assert.True(t, sans[1].Same, "the same certificate should be served for 127.0.0.1")
+	assert.False(t, sans[1].Uncovered(), "the same certificate served for 127.0.0.1 should pass the check")
+
+	reported, lines := certificate.ReportSANs(cert, rfc3339)
+	assert.Equal(t, len(reported), 2, "the subject alternative names should be reported as a JSON field")
+	assert.Contains(t, lines[0], "DIFFERS", "a different certificate should be called out in plain output")
+}
+
+func TestCheckSANsUncovered(t *testing.T) {
+	pki := newTestPKI(t)
+	wrong := newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "example.com"},
+		DNSNames:    []string{"example.com"},
+		NotBefore:   pki.leaf.cert.NotBefore,
+		NotAfter:    pki.leaf.cert.NotAfter,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, &pki.intermediate)
+
+	addr := serveTLS(t, &tls.Config{
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			// serve a certificate for another name to anyone asking for localhost
+			if hello.ServerName == "localhost" {
+				return &tls.Certificate{
+					Certificate: [][]byte{wrong.cert.Raw, pki.intermediate.cert.Raw},
+					PrivateKey:  wrong.key,
+				}, nil
+			}
+			current := pki.tlsCertificate()
+			return &current, nil
+		},
+	})
+	_, port, _ := net.SplitHostPort(addr)
+
+	cert, err := certificate.New(addr)
+	assert.Nil(t, err, "a host:port pair should parse")
+	cert.ListSANs = true
+	cert.CheckSANs = true
+	cert.Network.Resolve = map[string][]string{"localhost:" + port: {addr}}
+
+	sans, err := cert.SANs()
+	assert.Nil(t, err, "the certificate should be retrieved")
+	assert.Equal(t, sans[0].Value, "localhost")
+	assert.False(t, sans[0].Covered, "the certificate served for localhost shouldn't cover it")
+	assert.True(t, sans[0].Uncovered(), "a certificate not valid for localhost should fail the check")
+
+	_, lines := certificate.ReportSANs(cert, rfc3339)
+	assert.Contains(t, lines[0], "NOT COVERED", "an uncovered name should be called out in plain output")
+}
@@ -0,0 +1,350 @@
+package certificate
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+)
+
+// timeouts used when NetworkOptions leaves them unset
+const (
+	defaultConnectTimeout   = 1000 * time.Millisecond
+	defaultHandshakeTimeout = 1000 * time.Millisecond
+)
+
+// limits for HTTP requests made with NetworkOptions, e.g. to OCSP
+// responders; CRLs in particular can be both large and slow
+const (
+	fetchTimeout = 10 * time.Second
+	maxFetchSize = 32 << 20
+)
+
+// NetworkOptions controls how connections to a server are made.
+// The zero value connects once over IPv4 or IPv6, using default timeouts.
+type NetworkOptions struct {
+	// ConnectTimeout limits how long establishing a connection may take
+	ConnectTimeout time.Duration
+	// HandshakeTimeout limits how long negotiating TLS may take,
+	// including any STARTTLS negotiation beforehand
+	HandshakeTimeout time.Duration
+	// Retries is the number of times a failed connection is retried
+	Retries int
+	// RetryBackoff is the wait before the first retry,
+	// which doubles before each retry after that
+	RetryBackoff time.Duration
+	// Family restricts connections to "tcp4" or "tcp6"
+	Family string
+	// Source is the local address that connections are made from
+	Source net.IP
+	// Resolve maps host:port pairs to the addresses to connect to
+	// instead of resolving the host, like curl's --resolve
+	Resolve map[string][]string
+	// Proxy is an HTTP, HTTPS or SOCKS5 proxy that connections are
+	// tunneled through; connections are made directly when it is nil
+	Proxy *url.URL
+	// ProxyFromEnvironment is set when Proxy was chosen for the server
+	// according to the environment, so that HTTP requests to other
+	// hosts are made through the proxy the environment chooses for them
+	ProxyFromEnvironment bool
+}
+
+func (o NetworkOptions) network() string {
+	if o.Family != "" {
+		return o.Family
+	}
+
+	return "tcp"
+}
+
+func (o NetworkOptions) connectTimeout() time.Duration {
+	if o.ConnectTimeout > 0 {
+		return o.ConnectTimeout
+	}
+
+	return defaultConnectTimeout
+}
+
+func (o NetworkOptions) handshakeTimeout() time.Duration {
+	if o.HandshakeTimeout > 0 {
+		return o.HandshakeTimeout
+	}
+
+	return defaultHandshakeTimeout
+}
+
+// NetworkFlags are the command line flags that select NetworkOptions
+// relevant to both TLS connections and HTTP requests
+type NetworkFlags struct {
+	ConnectTimeout   time.Duration `name:"connect-timeout" default:"1s" help:"give up connecting after <duration>"`
+	HandshakeTimeout time.Duration `name:"handshake-timeout" default:"1s" help:"give up negotiating TLS after <duration>"`
+	IPv4             bool          `name:"ipv4" short:"4" xor:"family" help:"only connect over IPv4"`
+	IPv6             bool          `name:"ipv6" short:"6" xor:"family" help:"only connect over IPv6"`
+	Source           string        `name:"source" placeholder:"ADDR" help:"connect from local IP address <addr>"`
+	Proxy            string        `name:"proxy" xor:"proxy" placeholder:"URL" help:"connect through the HTTP CONNECT or SOCKS5 proxy at <url>; defaults to $HTTPS_PROXY ($HTTP_PROXY for http URLs) or $ALL_PROXY, unless excluded by $NO_PROXY"`
+	NoProxy          bool          `name:"no-proxy" xor:"proxy" help:"connect directly, ignoring any proxy set in the environment"`
+}
+
+// Options returns the NetworkOptions selected by the flags for
+// connections to target. The proxy set in the environment for target
+// is used unless a proxy is given, or NoProxy is set.
+func (f *NetworkFlags) Options(target *url.URL) (opts NetworkOptions, err error) {
+	opts = NetworkOptions{
+		ConnectTimeout:   f.ConnectTimeout,
+		HandshakeTimeout: f.HandshakeTimeout,
+	}
+
+	if f.IPv4 {
+		opts.Family = "tcp4"
+	} else if f.IPv6 {
+		opts.Family = "tcp6"
+	}
+
+	if f.Source != "" {
+		if opts.Source = net.ParseIP(f.Source); opts.Source == nil {
+			return opts, fmt.Errorf("source address %q is not a valid IP address", f.Source)
+		}
+	}
+
+	switch {
+	case f.Proxy != "":
+		// like curl, assume an HTTP proxy if no scheme is given
+		proxyURL := f.Proxy
+		if !strings.Contains(proxyURL, "://") {
+			proxyURL = "http://" + proxyURL
+		}
+
+		if opts.Proxy, err = url.Parse(proxyURL); err != nil {
+			return opts, fmt.Errorf("invalid proxy URL %q: %w", f.Proxy, err)
+		}
+	case !f.NoProxy:
+		opts.Proxy, err = proxyFromEnvironment(target)
+		opts.ProxyFromEnvironment = true
+	}
+
+	return opts, err
+}
+
+// inFamily reports whether connections to ip are allowed by Family
+func (o NetworkOptions) inFamily(ip net.IP) bool {
+	isIPv4 := ip.To4() != nil
+	return !(o.Family == "tcp4" && !isIPv4) && !(o.Family == "tcp6" && isIPv4)
+}
+
+// overrides returns the addresses in Family to connect to in place of
+// addr, or nil if addr should be resolved as usual. It is an error for
+// Resolve to replace addr with addresses outside of Family only.
+func (o NetworkOptions) overrides(addr string) ([]string, error) {
+	replacements := o.Resolve[strings.ToLower(addr)]
+	if replacements == nil {
+		return nil, nil
+	}
+
+	var overrides []string
+	for _, replacement := range replacements {
+		host, _, _ := net.SplitHostPort(replacement)
+		if o.inFamily(net.ParseIP(host)) {
+			overrides = append(overrides, replacement)
+		}
+	}
+
+	if len(overrides) == 0 {
+		return nil, fmt.Errorf("the resolve overrides for %v have no %s addresses", addr, o.network())
+	}
+
+	return overrides, nil
+}
+
+// httpClient returns a client for HTTP requests, such as those made
+// on the certificate's behalf. Each request is made through the proxy
+// the environment chooses for it if ProxyFromEnvironment is set, and
+// otherwise through Proxy if it is set.
+func (o NetworkOptions) httpClient() *http.Client {
+	dialer := &net.Dialer{Timeout: o.connectTimeout()}
+	if o.Source != nil {
+		dialer.LocalAddr = &net.TCPAddr{IP: o.Source}
+	}
+
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _ string, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, o.network(), addr)
+		},
+		TLSHandshakeTimeout: o.handshakeTimeout(),
+	}
+
+	switch {
+	case o.ProxyFromEnvironment:
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			return proxyFromEnvironment(req.URL)
+		}
+	case o.Proxy != nil:
+		transport.Proxy = http.ProxyURL(o.Proxy)
+	}
+
+	return &http.Client{Transport: transport, Timeout: fetchTimeout}
+}
+
+// Fetch sends req and returns the body of a successful response
+func (o NetworkOptions) Fetch(req *http.Request) ([]byte, error) {
+	slog.Debug("fetching", "method", req.Method, "url", req.URL.Redacted())
+	resp, err := o.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s: %s", req.Method, req.URL.Redacted(), resp.Status)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, maxFetchSize))
+}
+
+// handshake completes a TLS handshake with the server at addr and
+// returns the resulting connection state, retrying with backoff
+// as many times as allowed
+func (c *Certificate) handshake(addr string, tlsConfig *tls.Config) (tls.ConnectionState, error) {
+	backoff := c.Network.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		state, err := c.handshakeOnce(addr, tlsConfig)
+		if err == nil || attempt >= c.Network.Retries {
+			return state, err
+		}
+
+		slog.Debug("retrying connection",
+			"address", addr,
+			"attempt", attempt+1,
+			"backoff", backoff,
+			"error", err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// handshakeOnce completes a single TLS handshake
+// over TCP, or over QUIC if QUIC is set
+func (c *Certificate) handshakeOnce(addr string, tlsConfig *tls.Config) (tls.ConnectionState, error) {
+	if c.QUIC {
+		return c.handshakeQUIC(addr, tlsConfig)
+	}
+
+	conn, err := c.dial(addr, tlsConfig)
+	if err != nil {
+		return tls.ConnectionState{}, err
+	}
+
+	defer func() { _ = conn.Close() }()
+	return conn.ConnectionState(), nil
+}
+
+// dial connects to the server and completes a TLS handshake, negotiating
+// TLS in-band first if a StartTLS protocol has been given.
+func (c *Certificate) dial(addr string, tlsConfig *tls.Config) (*tls.Conn, error) {
+	var proto protocol
+	if c.StartTLS != "" {
+		var ok bool
+		if proto, ok = protocols[c.StartTLS]; !ok {
+			return nil, fmt.Errorf("unsupported STARTTLS protocol %q", c.StartTLS)
+		}
+	}
+
+	conn, err := c.dialTCP(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// the handshake timeout covers the plaintext
+	// negotiation as well as the TLS handshake
+	_ = conn.SetDeadline(time.Now().Add(c.Network.handshakeTimeout()))
+
+	if proto.upgrade != nil {
+		slog.Debug("negotiating STARTTLS", "address", addr, "protocol", c.StartTLS)
+		if err := proto.upgrade(conn, c.Name()); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("unable to negotiate TLS over %s: %w", c.StartTLS, err)
+		}
+	}
+
+	tlsConn := tls.Client(conn, tlsConfig)
+	if err := tlsConn.Handshake(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	_ = conn.SetDeadline(time.Time{})
+	return tlsConn, nil
+}
+
+// dialTCP opens a connection to addr, or to the first
+// reachable address that replaces it in Resolve
+func (c *Certificate) dialTCP(addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: c.Network.connectTimeout()}
+	if c.Network.Source != nil {
+		dialer.LocalAddr = &net.TCPAddr{IP: c.Network.Source}
+	}
+
+	targets := []string{addr}
+	overrides, err := c.Network.overrides(addr)
+	if err != nil {
+		return nil, err
+	}
+	if overrides != nil {
+		slog.Debug("using resolve overrides", "address", addr, "overrides", overrides)
+		targets = overrides
+	}
+
+	var errs []error
+	for _, target := range targets {
+		var conn net.Conn
+		var err error
+		if c.Network.Proxy != nil {
+			slog.Debug("connecting through proxy", "address", target, "proxy", c.Network.Proxy.Redacted())
+			conn, err = dialProxy(c.Network.Proxy, dialer, c.Network.network(), target,
+				c.Network.connectTimeout()+c.Network.handshakeTimeout())
+		} else {
+			conn, err = dialer.Dial(c.Network.network(), target)
+		}
+
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+	}
+
+	return nil, errors.Join(errs...)
+}
+
+// ParseResolve parses curl-style HOST:PORT:ADDR[,ADDR...] entries
+// into a map suitable for NetworkOptions.Resolve. IPv6 addresses
+// may be enclosed in brackets.
+func ParseResolve(entries []string) (map[string][]string, error) {
+	resolve := map[string][]string{}
+	for _, entry := range entries {
+		host, rest, _ := strings.Cut(entry, ":")
+		port, addrs, found := strings.Cut(rest, ":")
+		if host == "" || !found || addrs == "" || !govalidator.IsPort(port) {
+			return nil, fmt.Errorf("%q is not a valid HOST:PORT:ADDR entry", entry)
+		}
+
+		key := net.JoinHostPort(strings.ToLower(host), port)
+		for addr := range strings.SplitSeq(addrs, ",") {
+			ip := net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]"))
+			if ip == nil {
+				return nil, fmt.Errorf("%q in %q is not a valid IP address", addr, entry)
+			}
+			resolve[key] = append(resolve[key], net.JoinHostPort(ip.String(), port))
+		}
+	}
+
+	return resolve, nil
+}
@@ -0,0 +1,192 @@
+package certificate_test
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/likexian/gokit/assert"
+	"github.com/mckern/spiry/internal/certificate"
+)
+
+// serveFlaky closes the first failures connections it accepts
+// without a word, then completes a TLS handshake on the rest
+func serveFlaky(t *testing.T, config *tls.Config, failures int32) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	var accepted atomic.Int32
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			if accepted.Add(1) <= failures {
+				_ = conn.Close()
+				continue
+			}
+
+			go func() {
+				defer func() { _ = conn.Close() }()
+				_ = tls.Server(conn, config).Handshake()
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func TestResolveOverride(t *testing.T) {
+	pki := newTestPKI(t)
+	addr := serveTLS(t, &tls.Config{Certificates: []tls.Certificate{pki.tlsCertificate()}})
+	_, port, _ := net.SplitHostPort(addr)
+
+	resolve, err := certificate.ParseResolve([]string{"New.Backend.example.com:" + port + ":127.0.0.1"})
+	assert.Nil(t, err, "a HOST:PORT:ADDR entry should parse")
+
+	cert, _ := certificate.New("new.backend.example.com:" + port)
+	cert.Network.Resolve = resolve
+
+	expiry, err := cert.Expiry()
+	assert.Nil(t, err, "the overriding address should be connected to")
+	assert.True(t, expiry.Equal(pki.leaf.cert.NotAfter), "the leaf certificate should be retrieved")
+
+	cert, _ = certificate.New("new.backend.example.com:" + port)
+	cert.Network.Resolve = resolve
+	cert.Network.Family = "tcp6"
+
+	_, err = cert.Expiry()
+	assert.NotNil(t, err, "an IPv4 overriding address should not be dialed over IPv6")
+	assert.Contains(t, err.Error(), "no tcp6 addresses", "the address family should be called out")
+}
+
+func TestParseResolve(t *testing.T) {
+	resolve, err := certificate.ParseResolve([]string{"example.com:443:[2001:db8::1],192.0.2.1"})
+	assert.Nil(t, err, "multiple addresses should parse")
+	assert.Equal(t, resolve["example.com:443"], []string{"[2001:db8::1]:443", "192.0.2.1:443"},
+		"every address should be joined with the port")
+
+	for _, entry := range []string{"example.com", "example.com:443", "example.com:https:192.0.2.1", "example.com:443:nope"} {
+		_, err := certificate.ParseResolve([]string{entry})
+		assert.NotNil(t, err, "an invalid entry should raise an error")
+	}
+}
+
+func TestHandshakeTimeout(t *testing.T) {
+	// accept connections, and then say nothing at all
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer func() { _ = listener.Close() }()
+	go func() {
+		for {
+			if _, err := listener.Accept(); err != nil {
+				return
+			}
+		}
+	}()
+
+	cert, _ := certificate.New(listener.Addr().String())
+	cert.Network.HandshakeTimeout = 50 * time.Millisecond
+
+	start := time.Now()
+	_, err = cert.Expiry()
+	assert.NotNil(t, err, "a silent server should time out")
+	assert.True(t, time.Since(start) < time.Second, "the handshake timeout should be honored")
+}
+
+func TestRetries(t *testing.T) {
+	pki := newTestPKI(t)
+	config := &tls.Config{Certificates: []tls.Certificate{pki.tlsCertificate()}}
+
+	cert, _ := certificate.New(serveFlaky(t, config, 2))
+	cert.Network.Retries = 1
+	cert.Network.RetryBackoff = time.Millisecond
+	_, err := cert.Expiry()
+	assert.NotNil(t, err, "too few retries should fail")
+
+	cert, _ = certificate.New(serveFlaky(t, config, 2))
+	cert.Network.Retries = 2
+	cert.Network.RetryBackoff = time.Millisecond
+	_, err = cert.Expiry()
+	assert.Nil(t, err, "enough retries should succeed")
+}
+
+func TestAddressFamily(t *testing.T) {
+	pki := newTestPKI(t)
+	addr := serveTLS(t, &tls.Config{Certificates: []tls.Certificate{pki.tlsCertificate()}})
+
+	cert, _ := certificate.New(addr)
+	cert.Network.Family = "tcp6"
+	_, err := cert.Expiry()
+	assert.NotNil(t, err, "an IPv4 address should not be dialed over IPv6")
+
+	cert, _ = certificate.New(addr)
+	cert.Network.Family = "tcp4"
+	cert.Network.Source = net.IPv4(127, 0, 0, 1)
+	_, err = cert.Expiry()
+	assert.Nil(t, err, "an IPv4 address should be dialed over IPv4 from a local address")
+}
+
+func TestFetchProxyFromEnvironment(t *testing.T) {
+	// the proxy answers every request itself, naming the host asked for
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "proxied "+r.URL.Host)
+	}))
+	t.Cleanup(proxy.Close)
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "direct")
+	}))
+	t.Cleanup(origin.Close)
+
+	for _, name := range []string{"HTTP_PROXY", "http_proxy", "HTTPS_PROXY", "https_proxy", "ALL_PROXY", "all_proxy", "no_proxy"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("HTTP_PROXY", proxy.URL)
+	t.Setenv("HTTPS_PROXY", proxy.URL)
+	target := &url.URL{Scheme: "https", Host: "backend.example.com:443"}
+
+	fetch := func(opts certificate.NetworkOptions, rawURL string) string {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, rawURL, nil)
+		body, err := opts.Fetch(req)
+		assert.Nil(t, err, "the request should succeed")
+		return string(body)
+	}
+
+	// the server is excluded from proxying, but the CRL's host is not
+	t.Setenv("NO_PROXY", "backend.example.com")
+	opts, err := (&certificate.NetworkFlags{}).Options(target)
+	assert.Nil(t, err)
+	assert.True(t, opts.Proxy == nil, "the server should be connected to directly")
+	assert.Equal(t, fetch(opts, "http://crl.example.net/ca.crl"), "proxied crl.example.net",
+		"a request to a host that isn't excluded should be proxied")
+
+	// the server is proxied, but the origin is excluded from proxying
+	t.Setenv("NO_PROXY", "localhost")
+	opts, err = (&certificate.NetworkFlags{}).Options(target)
+	assert.Nil(t, err)
+	assert.NotNil(t, opts.Proxy, "the server should be connected to through the proxy")
+	assert.Equal(t, fetch(opts, strings.Replace(origin.URL, "127.0.0.1", "localhost", 1)), "direct",
+		"a request to an excluded host should be made directly")
+
+	// a proxy given explicitly is used for every request
+	opts, err = (&certificate.NetworkFlags{Proxy: proxy.URL}).Options(target)
+	assert.Nil(t, err)
+	assert.Equal(t, fetch(opts, "http://crl.example.net/ca.crl"), "proxied crl.example.net",
+		"an explicit proxy should be used for every request")
+}
@@ -0,0 +1,162 @@
+package certificate_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// testCert is a certificate and its private key, as issued by newTestCert
+type testCert struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+// testPKI is a three-tier certificate hierarchy for use in tests
+type testPKI struct {
+	root         testCert
+	intermediate testCert
+	leaf         testCert
+}
+
+// newTestCert issues a certificate from template, signed by parent; a nil
+// parent produces a self-signed certificate.
+func newTestCert(t *testing.T, template *x509.Certificate, parent *testCert) testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return newTestCertWithKey(t, template, parent, key)
+}
+
+// newTestCertWithKey issues a certificate for key from template, as
+// newTestCert does
+func newTestCertWithKey(t *testing.T, template *x509.Certificate, parent *testCert, key crypto.Signer) testCert {
+	t.Helper()
+
+	if template.SerialNumber == nil {
+		serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+		if err != nil {
+			t.Fatal(err)
+		}
+		template.SerialNumber = serial
+	}
+
+	issuer, signer := template, crypto.Signer(key)
+	if parent != nil {
+		issuer, signer = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, key.Public(), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return testCert{cert: cert, key: key}
+}
+
+// newTestPKI builds a root, intermediate and leaf certificate. The
+// intermediate expires before the leaf, so that chain inspection has
+// something to find.
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+
+	now := time.Now().Truncate(time.Second)
+	pki := &testPKI{}
+
+	pki.root = newTestCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "spiry test root"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil)
+
+	pki.intermediate = newTestCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "spiry test intermediate"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(0, 0, 30),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, &pki.root)
+
+	pki.leaf = newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "localhost"},
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:   now.Add(-time.Hour),
+		NotAfter:    now.AddDate(0, 0, 90),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, &pki.intermediate)
+
+	return pki
+}
+
+// tlsCertificate returns the leaf and intermediate as a tls.Certificate
+// suitable for serving
+func (p *testPKI) tlsCertificate() tls.Certificate {
+	return tls.Certificate{
+		Certificate: [][]byte{p.leaf.cert.Raw, p.intermediate.cert.Raw},
+		PrivateKey:  p.leaf.key,
+		Leaf:        p.leaf.cert,
+	}
+}
+
+// serveTLS accepts TLS connections on a loopback address until the test
+// completes, and returns the address being listened on.
+func serveTLS(t *testing.T, config *tls.Config) string {
+	t.Helper()
+	return serveTLSOn(t, "127.0.0.1:0", config)
+}
+
+// serveTLSOn accepts TLS connections on addr until the test
+// completes, and returns the address being listened on.
+func serveTLSOn(t *testing.T, addr string, config *tls.Config) string {
+	t.Helper()
+
+	listener, err := tls.Listen("tcp", addr, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer func() { _ = conn.Close() }()
+				_ = conn.(*tls.Conn).Handshake()
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+// rfc3339 formats times in reports as RFC 3339 timestamps
+func rfc3339(t time.Time) string {
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,140 @@
+package certificate
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/net/proxy"
+)
+
+// proxyFromEnvironment returns the proxy that requests to target should
+// use according to $HTTPS_PROXY or $HTTP_PROXY, depending on its scheme,
+// or $ALL_PROXY in their absence, and $NO_PROXY. It returns nil if
+// requests should be made directly.
+func proxyFromEnvironment(target *url.URL) (*url.URL, error) {
+	config := httpproxy.FromEnvironment()
+	all := getenvAny("ALL_PROXY", "all_proxy")
+	if config.HTTPSProxy == "" {
+		config.HTTPSProxy = all
+	}
+	if config.HTTPProxy == "" {
+		config.HTTPProxy = all
+	}
+
+	return config.ProxyFunc()(target)
+}
+
+// getenvAny returns the value of the first of the
+// environment variables that is set and not empty
+func getenvAny(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// dialProxy opens a connection to addr through the proxy at proxyURL,
+// using dialer to reach the proxy itself. Reaching the proxy and
+// negotiating the tunnel must both finish within timeout.
+func dialProxy(proxyURL *url.URL, dialer *net.Dialer, network string, addr string, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	switch proxyURL.Scheme {
+	case "http", "https":
+		return dialConnect(ctx, proxyURL, dialer, network, addr)
+	case "socks5", "socks5h":
+		var auth *proxy.Auth
+		if proxyURL.User != nil {
+			password, _ := proxyURL.User.Password()
+			auth = &proxy.Auth{User: proxyURL.User.Username(), Password: password}
+		}
+
+		socks, err := proxy.SOCKS5(network, proxyURL.Host, auth, dialer)
+		if err != nil {
+			return nil, err
+		}
+
+		// the SOCKS5 dialer only enforces a deadline through a context
+		return socks.(proxy.ContextDialer).DialContext(ctx, network, addr)
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+	}
+}
+
+// dialConnect opens a tunnel to addr through an HTTP proxy, using the
+// CONNECT method, before ctx's deadline. HTTPS proxies are spoken to
+// over TLS.
+func dialConnect(ctx context.Context, proxyURL *url.URL, dialer *net.Dialer, network string, addr string) (net.Conn, error) {
+	proxyAddr := proxyURL.Host
+	if proxyURL.Port() == "" {
+		port := "80"
+		if proxyURL.Scheme == "https" {
+			port = "443"
+		}
+		proxyAddr = net.JoinHostPort(proxyURL.Hostname(), port)
+	}
+
+	conn, err := dialer.DialContext(ctx, network, proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	// a proxy that accepts the connection and then says nothing
+	// must not be able to hang the request indefinitely
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+
+	if proxyURL.Scheme == "https" {
+		conn = tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname()})
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		credentials := proxyURL.User.Username() + ":" + password
+		req.Header.Set("Proxy-Authorization",
+			"Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
+	}
+
+	if err := req.Write(conn); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	// the proxy won't send anything past its response
+	// until the TLS handshake begins, so nothing that
+	// the buffered reader reads ahead will be lost
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		_ = conn.Close()
+		return nil, fmt.Errorf("proxy %v refused to connect to %v: %s", proxyURL.Redacted(), addr, resp.Status)
+	}
+
+	_ = conn.SetDeadline(time.Time{})
+	return conn, nil
+}
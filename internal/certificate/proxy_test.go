@@ -0,0 +1,238 @@
+package certificate_test
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/likexian/gokit/assert"
+	"github.com/mckern/spiry/internal/certificate"
+)
+
+// serveProxy accepts connections on a loopback address and hands each
+// one to handshake, which returns the address the client asked for.
+// Every tunnel leads to backend, regardless of what was asked for, and
+// the addresses asked for are sent to requested.
+func serveProxy(t *testing.T, backend string, handshake func(conn net.Conn) (string, error)) (addr string, requested chan string) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	requested = make(chan string, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer func() { _ = conn.Close() }()
+
+				target, err := handshake(conn)
+				if err != nil {
+					return
+				}
+				requested <- target
+
+				upstream, err := net.Dial("tcp", backend)
+				if err != nil {
+					return
+				}
+				defer func() { _ = upstream.Close() }()
+
+				go func() { _, _ = io.Copy(upstream, conn) }()
+				_, _ = io.Copy(conn, upstream)
+			}()
+		}
+	}()
+
+	return listener.Addr().String(), requested
+}
+
+// connectHandshake plays an HTTP proxy, requiring Basic authentication
+// as alice:secret
+func connectHandshake(conn net.Conn) (string, error) {
+	req, err := http.ReadRequest(bufio.NewReader(conn))
+	if err != nil {
+		return "", err
+	}
+
+	want := "Basic " + base64.StdEncoding.EncodeToString([]byte("alice:secret"))
+	if req.Method != http.MethodConnect || req.Header.Get("Proxy-Authorization") != want {
+		_, _ = io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+		return "", io.EOF
+	}
+
+	_, err = io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+	return req.Host, err
+}
+
+// socksHandshake plays a SOCKS5 proxy (RFC 1928), requiring
+// username and password authentication (RFC 1929) as alice:secret
+func socksHandshake(conn net.Conn) (string, error) {
+	r := bufio.NewReader(conn)
+
+	// version, and the authentication methods offered
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return "", err
+	}
+	if _, err := io.ReadFull(r, make([]byte, header[1])); err != nil {
+		return "", err
+	}
+	_, _ = conn.Write([]byte{5, 2})
+
+	// username and password subnegotiation
+	auth := make([]byte, 2)
+	if _, err := io.ReadFull(r, auth); err != nil {
+		return "", err
+	}
+	user := make([]byte, auth[1])
+	_, _ = io.ReadFull(r, user)
+	length, _ := r.ReadByte()
+	password := make([]byte, length)
+	_, _ = io.ReadFull(r, password)
+	if string(user) != "alice" || string(password) != "secret" {
+		_, _ = conn.Write([]byte{1, 1})
+		return "", io.EOF
+	}
+	_, _ = conn.Write([]byte{1, 0})
+
+	// the CONNECT request itself
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(r, request); err != nil {
+		return "", err
+	}
+
+	var host string
+	switch request[3] {
+	case 1:
+		ip := make([]byte, 4)
+		_, _ = io.ReadFull(r, ip)
+		host = net.IP(ip).String()
+	case 3:
+		length, _ := r.ReadByte()
+		name := make([]byte, length)
+		_, _ = io.ReadFull(r, name)
+		host = string(name)
+	default:
+		return "", io.EOF
+	}
+
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(r, port); err != nil {
+		return "", err
+	}
+
+	_, err := conn.Write([]byte{5, 0, 0, 1, 127, 0, 0, 1, 0, 0})
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), err
+}
+
+func TestProxy(t *testing.T) {
+	pki := newTestPKI(t)
+	backend := serveTLS(t, &tls.Config{Certificates: []tls.Certificate{pki.tlsCertificate()}})
+
+	var proxyTests = []struct {
+		name      string
+		scheme    string
+		handshake func(conn net.Conn) (string, error)
+		user      *url.Userinfo
+		wantErr   bool
+	}{
+		{name: "HTTP CONNECT", scheme: "http", handshake: connectHandshake, user: url.UserPassword("alice", "secret")},
+		{name: "HTTP CONNECT with the wrong password", scheme: "http", handshake: connectHandshake,
+			user: url.UserPassword("alice", "hunter2"), wantErr: true},
+		{name: "SOCKS5", scheme: "socks5", handshake: socksHandshake, user: url.UserPassword("alice", "secret")},
+		{name: "SOCKS5 with the wrong password", scheme: "socks5", handshake: socksHandshake,
+			user: url.UserPassword("alice", "hunter2"), wantErr: true},
+	}
+
+	for _, tt := range proxyTests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxyAddr, requested := serveProxy(t, backend, tt.handshake)
+
+			cert, _ := certificate.New("backend.example.com:8443")
+			cert.Network.Proxy = &url.URL{Scheme: tt.scheme, Host: proxyAddr, User: tt.user}
+
+			expiry, err := cert.Expiry()
+			if tt.wantErr {
+				assert.NotNil(t, err, "a proxy refusing the connection should raise an error")
+				return
+			}
+
+			assert.Nil(t, err, "a certificate should be retrieved through the proxy")
+			assert.True(t, expiry.Equal(pki.leaf.cert.NotAfter), "the leaf certificate should be retrieved")
+			assert.Equal(t, <-requested, "backend.example.com:8443", "the proxy should be asked for the server's address")
+		})
+	}
+}
+
+func TestProxySilent(t *testing.T) {
+	// the proxy accepts connections, and then never says anything
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { _ = conn.Close() })
+		}
+	}()
+
+	for _, scheme := range []string{"http", "https", "socks5"} {
+		t.Run(scheme, func(t *testing.T) {
+			cert, _ := certificate.New("backend.example.com:8443")
+			cert.Network.Proxy = &url.URL{Scheme: scheme, Host: listener.Addr().String()}
+			cert.Network.ConnectTimeout = 100 * time.Millisecond
+			cert.Network.HandshakeTimeout = 100 * time.Millisecond
+
+			start := time.Now()
+			_, err := cert.Expiry()
+			assert.NotNil(t, err, "a silent proxy should raise an error")
+			assert.True(t, time.Since(start) < 2*time.Second, "a silent proxy should time out")
+		})
+	}
+}
+
+func TestProxyFromEnvironment(t *testing.T) {
+	target := &url.URL{Scheme: "https", Host: "backend.example.com:8443"}
+
+	t.Setenv("HTTPS_PROXY", "")
+	t.Setenv("https_proxy", "")
+	t.Setenv("ALL_PROXY", "socks5://proxy.example.net:1080")
+	t.Setenv("NO_PROXY", "")
+	t.Setenv("no_proxy", "")
+
+	proxyURL, err := certificate.ProxyFromEnvironment(target)
+	assert.Nil(t, err)
+	assert.Equal(t, proxyURL.String(), "socks5://proxy.example.net:1080", "$ALL_PROXY should be used in the absence of $HTTPS_PROXY")
+
+	t.Setenv("HTTPS_PROXY", "http://proxy.example.net:3128")
+	proxyURL, err = certificate.ProxyFromEnvironment(target)
+	assert.Nil(t, err)
+	assert.Equal(t, proxyURL.String(), "http://proxy.example.net:3128", "$HTTPS_PROXY should be preferred")
+
+	t.Setenv("NO_PROXY", ".example.com")
+	proxyURL, err = certificate.ProxyFromEnvironment(target)
+	assert.Nil(t, err)
+	assert.True(t, proxyURL == nil, "$NO_PROXY should be honored")
+}
@@ -0,0 +1,144 @@
+package certificate
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/quic-go/quic-go"
+)
+
+// defaultQUICProtocol is offered over ALPN when no other application
+// protocol is given, because QUIC handshakes require one
+const defaultQUICProtocol = "h3"
+
+// handshakeQUIC completes a QUIC handshake with the server at addr,
+// or with the first reachable address that replaces it in Resolve
+func (c *Certificate) handshakeQUIC(addr string, tlsConfig *tls.Config) (tls.ConnectionState, error) {
+	if c.StartTLS != "" {
+		return tls.ConnectionState{}, errors.New("STARTTLS cannot be negotiated over QUIC")
+	}
+
+	if c.Network.Proxy != nil {
+		return tls.ConnectionState{}, errors.New("QUIC connections cannot be made through a proxy")
+	}
+
+	tlsConfig = tlsConfig.Clone()
+	if len(tlsConfig.NextProtos) == 0 {
+		tlsConfig.NextProtos = []string{defaultQUICProtocol}
+	}
+
+	targets := []string{addr}
+	overrides, err := c.Network.overrides(addr)
+	if err != nil {
+		return tls.ConnectionState{}, err
+	}
+	if overrides != nil {
+		targets = overrides
+	}
+
+	var errs []error
+	for _, target := range targets {
+		state, err := c.dialQUIC(target, tlsConfig)
+		if err == nil {
+			return state, nil
+		}
+		errs = append(errs, err)
+	}
+
+	return tls.ConnectionState{}, errors.Join(errs...)
+}
+
+// dialQUIC completes a QUIC handshake with addr
+func (c *Certificate) dialQUIC(addr string, tlsConfig *tls.Config) (tls.ConnectionState, error) {
+	// QUIC runs over UDP, in whichever address family was asked for
+	network := "udp"
+	switch c.Network.Family {
+	case "tcp4":
+		network = "udp4"
+	case "tcp6":
+		network = "udp6"
+	}
+
+	udpAddr, err := net.ResolveUDPAddr(network, addr)
+	if err != nil {
+		return tls.ConnectionState{}, err
+	}
+
+	udpConn, err := net.ListenUDP(network, &net.UDPAddr{IP: c.Network.Source})
+	if err != nil {
+		return tls.ConnectionState{}, err
+	}
+	defer func() { _ = udpConn.Close() }()
+
+	transport := &quic.Transport{Conn: udpConn}
+	defer func() { _ = transport.Close() }()
+
+	// there is no separate connection step for QUIC,
+	// so both timeouts apply to the handshake
+	timeout := c.Network.connectTimeout() + c.Network.handshakeTimeout()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	slog.Debug("dialing QUIC", "address", addr, "alpn", tlsConfig.NextProtos)
+	conn, err := transport.Dial(ctx, udpAddr, tlsConfig, &quic.Config{HandshakeIdleTimeout: timeout})
+	if err != nil {
+		return tls.ConnectionState{}, err
+	}
+
+	defer func() { _ = conn.CloseWithError(0, "") }()
+	return conn.ConnectionState().TLS, nil
+}
+
+// compareQUIC retrieves the certificate chain over QUIC,
+// for comparison with the chain retrieved over TCP
+func (c *Certificate) compareQUIC() {
+	quicOnly := *c
+	quicOnly.QUIC = true
+	c.quicChain, c.quicErr = quicOnly.getChain(c.addr)
+}
+
+// QUICMatches reports whether the leaf certificate served over QUIC
+// is the same as the one served over TCP, when CompareQUIC is set
+func (c *Certificate) QUICMatches() (bool, error) {
+	if _, err := c.Expiry(); err != nil {
+		return false, err
+	}
+
+	if c.quicErr != nil {
+		return false, c.quicErr
+	}
+
+	return sha256.Sum256(c.quicChain[0].Raw) == sha256.Sum256(c.raw.Raw), nil
+}
+
+// reportQUIC describes the certificate served over QUIC,
+// and whether it matches the one served over TCP
+func (c *Certificate) reportQUIC(format func(time.Time) string) (fields map[string]any, lines []string) {
+	matches, err := c.QUICMatches()
+	if err != nil {
+		return map[string]any{"error": err.Error()},
+			[]string{fmt.Sprintf("  quic\terror=%q", err)}
+	}
+
+	d := DetailsOf(c.quicChain[0])
+	expiry := format(c.chainExpiry(c.quicChain))
+	fields = map[string]any{
+		"expiry":            expiry,
+		"serialNumber":      d.SerialNumber,
+		"sha256Fingerprint": d.Fingerprint,
+		"matchesTCP":        matches,
+	}
+
+	line := fmt.Sprintf("  quic\texpiry=%s\tsha256=%s", expiry, d.Fingerprint)
+	if !matches {
+		line += "\tDIFFERS from TCP"
+	}
+
+	return fields, []string{line}
+}
@@ -0,0 +1,101 @@
+package certificate_test
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+	"time"
+
+	"github.com/likexian/gokit/assert"
+	"github.com/mckern/spiry/internal/certificate"
+	"github.com/quic-go/quic-go"
+)
+
+// serveQUIC accepts QUIC connections on a loopback address until the
+// test completes, and returns the address being listened on.
+func serveQUIC(t *testing.T, config *tls.Config) string {
+	t.Helper()
+
+	config = config.Clone()
+	config.NextProtos = []string{"h3"}
+
+	listener, err := quic.ListenAddr("127.0.0.1:0", config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			// the handshake is complete once a connection is accepted,
+			// and the client closes it when it is done
+			if _, err := listener.Accept(context.Background()); err != nil {
+				return
+			}
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func TestQUIC(t *testing.T) {
+	pki := newTestPKI(t)
+	addr := serveQUIC(t, &tls.Config{Certificates: []tls.Certificate{pki.tlsCertificate()}})
+
+	cert, err := certificate.New(addr)
+	assert.Nil(t, err, "a host:port pair should parse")
+	cert.QUIC = true
+
+	expiry, err := cert.Expiry()
+	assert.Nil(t, err, "the certificate should be retrieved over QUIC")
+	assert.Equal(t, expiry, pki.leaf.cert.NotAfter, "the leaf's expiry should be reported")
+}
+
+func TestQUICWithStartTLS(t *testing.T) {
+	cert, err := certificate.New("smtp://localhost")
+	assert.Nil(t, err, "an smtp:// URL should parse")
+	cert.QUIC = true
+
+	_, err = cert.Expiry()
+	assert.NotNil(t, err, "STARTTLS should be refused over QUIC")
+}
+
+func TestCompareQUIC(t *testing.T) {
+	tcp, udp := newTestPKI(t), newTestPKI(t)
+
+	addr := serveQUIC(t, &tls.Config{Certificates: []tls.Certificate{udp.tlsCertificate()}})
+	serveTLSOn(t, addr, &tls.Config{Certificates: []tls.Certificate{tcp.tlsCertificate()}})
+
+	cert, err := certificate.New(addr)
+	assert.Nil(t, err, "a host:port pair should parse")
+	cert.CompareQUIC = true
+
+	_, err = cert.Expiry()
+	assert.Nil(t, err, "the certificate should be retrieved over TCP and QUIC")
+
+	matches, err := cert.QUICMatches()
+	assert.Nil(t, err, "the QUIC certificate should be retrieved")
+	assert.False(t, matches, "a different certificate over QUIC should be called out")
+
+	fields, lines := certificate.ReportQUIC(cert, rfc3339)
+	assert.Equal(t, fields["matchesTCP"], false, "the QUIC certificate should be reported as a JSON field")
+	assert.Contains(t, lines[len(lines)-1], "DIFFERS", "a different certificate should be called out in plain output")
+}
+
+func TestCompareQUICUnreachable(t *testing.T) {
+	pki := newTestPKI(t)
+	addr := serveTLS(t, &tls.Config{Certificates: []tls.Certificate{pki.tlsCertificate()}})
+
+	cert, err := certificate.New(addr)
+	assert.Nil(t, err, "a host:port pair should parse")
+	cert.CompareQUIC = true
+	cert.Network.ConnectTimeout = 100 * time.Millisecond
+	cert.Network.HandshakeTimeout = 100 * time.Millisecond
+
+	expiry, err := cert.Expiry()
+	assert.Nil(t, err, "a missing QUIC listener should not fail the TCP check")
+	assert.Equal(t, expiry, pki.leaf.cert.NotAfter, "the TCP certificate's expiry should be reported")
+
+	_, err = cert.QUICMatches()
+	assert.NotNil(t, err, "a missing QUIC listener should be reported")
+}
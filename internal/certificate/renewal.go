@@ -0,0 +1,75 @@
+package certificate
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultRenewalThreshold is the fraction of its lifetime after which a
+// certificate is due for renewal, when no other threshold is given. ACME
+// clients renew at two thirds of a certificate's lifetime.
+const DefaultRenewalThreshold = 2.0 / 3.0
+
+// Renewal describes how much of the leaf certificate's
+// lifetime has been used up, and when it is due for renewal
+type Renewal struct {
+	Lifetime time.Duration
+	// Consumed is the percentage of the lifetime that has passed,
+	// which can be less than 0 or more than 100
+	Consumed float64
+	// RenewAt is when the certificate is due for renewal,
+	// and Overdue is set once that time has passed
+	RenewAt time.Time
+	Overdue bool
+}
+
+// Renewal reports how much of the leaf certificate's lifetime has been
+// consumed, and whether it is overdue for renewal at RenewalThreshold
+func (c *Certificate) Renewal() (*Renewal, error) {
+	if _, err := c.Expiry(); err != nil {
+		return nil, err
+	}
+
+	threshold := c.RenewalThreshold
+	if threshold <= 0 {
+		threshold = DefaultRenewalThreshold
+	}
+
+	return renewalOf(c.raw.NotBefore, c.raw.NotAfter, threshold, time.Now()), nil
+}
+
+// renewalOf describes the renewal window of a certificate valid from
+// notBefore to notAfter, renewed once threshold of its lifetime has passed
+func renewalOf(notBefore time.Time, notAfter time.Time, threshold float64, now time.Time) *Renewal {
+	lifetime := notAfter.Sub(notBefore)
+	r := &Renewal{
+		Lifetime: lifetime,
+		RenewAt:  notBefore.Add(time.Duration(float64(lifetime) * threshold)),
+	}
+
+	if lifetime > 0 {
+		r.Consumed = 100 * float64(now.Sub(notBefore)) / float64(lifetime)
+	}
+
+	r.Overdue = !now.Before(r.RenewAt)
+	return r
+}
+
+// report returns r as JSON fields and lines of plain output,
+// using format for any times
+func (r *Renewal) report(format func(time.Time) string) (fields map[string]any, lines []string) {
+	fields = map[string]any{
+		"lifetimeDays":    r.Lifetime.Hours() / 24,
+		"percentConsumed": r.Consumed,
+		"renewAt":         format(r.RenewAt),
+		"overdue":         r.Overdue,
+	}
+
+	line := fmt.Sprintf("  renewal: %.1f%% of %.0f day lifetime consumed, due %s",
+		r.Consumed, r.Lifetime.Hours()/24, format(r.RenewAt))
+	if r.Overdue {
+		line += ", OVERDUE"
+	}
+
+	return fields, []string{line}
+}
@@ -0,0 +1,63 @@
+package certificate_test
+
+import (
+	"crypto/tls"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/likexian/gokit/assert"
+	"github.com/mckern/spiry/internal/certificate"
+)
+
+func TestRenewalOf(t *testing.T) {
+	notBefore := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name      string
+		lifetime  time.Duration
+		left      time.Duration
+		threshold float64
+		consumed  float64
+		overdue   bool
+	}{
+		{name: "90 days, 25 left", lifetime: 90 * day, left: 25 * day, threshold: certificate.DefaultRenewalThreshold, consumed: 72.2, overdue: true},
+		{name: "90 days, 40 left", lifetime: 90 * day, left: 40 * day, threshold: certificate.DefaultRenewalThreshold, consumed: 55.6},
+		{name: "1 year, 25 left at 95%", lifetime: 365 * day, left: 25 * day, threshold: 0.95, consumed: 93.2},
+		{name: "expired", lifetime: 90 * day, left: -10 * day, threshold: certificate.DefaultRenewalThreshold, consumed: 111.1, overdue: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notAfter := notBefore.Add(tt.lifetime)
+			r := certificate.RenewalOf(notBefore, notAfter, tt.threshold, notAfter.Add(-tt.left))
+
+			assert.Equal(t, r.Lifetime, tt.lifetime)
+			assert.Equal(t, math.Round(r.Consumed*10)/10, tt.consumed)
+			assert.Equal(t, r.Overdue, tt.overdue)
+		})
+	}
+}
+
+func TestRenewal(t *testing.T) {
+	pki := newTestPKI(t)
+	addr := serveTLS(t, &tls.Config{Certificates: []tls.Certificate{pki.tlsCertificate()}})
+
+	cert, err := certificate.New(addr)
+	assert.Nil(t, err, "a host:port pair should parse")
+	cert.RenewalWindow = true
+
+	r, err := cert.Renewal()
+	assert.Nil(t, err, "the certificate should be retrieved")
+	assert.False(t, r.Overdue, "a fresh certificate should not be overdue")
+
+	cert.RenewalThreshold = 0.0001
+	r, err = cert.Renewal()
+	assert.Nil(t, err, "the certificate should be retrieved")
+	assert.True(t, r.Overdue, "a certificate past its threshold should be overdue")
+
+	fields, lines := certificate.ReportOf(r, rfc3339)
+	assert.Equal(t, fields["overdue"], true, "the renewal window should be reported as a JSON field")
+	assert.Contains(t, lines[0], "OVERDUE", "an overdue certificate should be called out in plain output")
+}
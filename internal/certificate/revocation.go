@@ -0,0 +1,249 @@
+package certificate
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// sources of revocation information
+const (
+	RevocationSourceOCSP = "ocsp"
+	RevocationSourceCRL  = "crl"
+)
+
+// revocationReasons names the CRLReason codes of RFC 5280, section 5.3.1
+var revocationReasons = map[int]string{
+	ocsp.Unspecified:          "unspecified",
+	ocsp.KeyCompromise:        "keyCompromise",
+	ocsp.CACompromise:         "cACompromise",
+	ocsp.AffiliationChanged:   "affiliationChanged",
+	ocsp.Superseded:           "superseded",
+	ocsp.CessationOfOperation: "cessationOfOperation",
+	ocsp.CertificateHold:      "certificateHold",
+	ocsp.RemoveFromCRL:        "removeFromCRL",
+	ocsp.PrivilegeWithdrawn:   "privilegeWithdrawn",
+	ocsp.AACompromise:         "aACompromise",
+}
+
+// Revocation is the outcome of checking whether
+// the leaf certificate has been revoked
+type Revocation struct {
+	// Status is "good", "revoked" or "unknown"
+	Status string
+	// Source is where the status came from, "ocsp" or "crl",
+	// and URL is the responder or CRL that was consulted
+	Source    string
+	URL       string
+	Reason    string
+	RevokedAt time.Time
+	// Errors explains every failed attempt to learn the status
+	Errors []string
+}
+
+// Revoked reports whether the certificate is known to be revoked
+func (r *Revocation) Revoked() bool {
+	return r.Status == ocspStatuses[ocsp.Revoked]
+}
+
+// report returns r as JSON fields and lines of plain output,
+// using format for any times
+func (r *Revocation) report(format func(time.Time) string) (fields map[string]any, lines []string) {
+	fields = map[string]any{
+		"status": r.Status,
+		"errors": nonNil(r.Errors),
+	}
+
+	line := "  revocation status: " + r.Status
+	if r.Source != "" {
+		fields["source"] = r.Source
+		fields["url"] = r.URL
+		line += fmt.Sprintf(" (%s %s)", r.Source, r.URL)
+	}
+
+	if r.Revoked() {
+		fields["revokedAt"] = format(r.RevokedAt)
+		fields["reason"] = r.Reason
+		line += ", revoked " + format(r.RevokedAt)
+		if r.Reason != "" {
+			line += ", reason " + r.Reason
+		}
+	}
+
+	lines = append(lines, line)
+	for _, err := range r.Errors {
+		lines = append(lines, "  revocation check failed: "+err)
+	}
+
+	return
+}
+
+// Revocation asks the OCSP responders named in the leaf certificate's
+// AIA extension whether it has been revoked, falling back to its CRL
+// distribution points when none of them give an answer. The issuer may
+// be one fetched by ChaseAIA when the server fails to present it.
+func (c *Certificate) Revocation() (*Revocation, error) {
+	if c.revocation != nil {
+		return c.revocation, nil
+	}
+
+	if _, err := c.PeerCertificates(); err != nil {
+		return nil, err
+	}
+
+	c.revocation = c.checkRevocation(c.fullChain())
+	return c.revocation, nil
+}
+
+func (c *Certificate) checkRevocation(chain []*x509.Certificate) *Revocation {
+	r := &Revocation{Status: ocspStatuses[ocsp.Unknown]}
+	leaf := chain[0]
+
+	if len(chain) < 2 {
+		r.Errors = append(r.Errors, "the issuer of the certificate was not presented, so its status cannot be checked")
+		return r
+	}
+	issuer := chain[1]
+
+	if len(leaf.OCSPServer) == 0 && len(leaf.CRLDistributionPoints) == 0 {
+		r.Errors = append(r.Errors, "the certificate names no OCSP responders or CRL distribution points")
+		return r
+	}
+
+	for _, server := range leaf.OCSPServer {
+		resp, err := c.queryOCSP(server, leaf, issuer)
+		if err != nil {
+			r.Errors = append(r.Errors, fmt.Sprintf("%s: %v", server, err))
+			continue
+		}
+
+		// an unknown status means the responder doesn't know the
+		// certificate, so another source may still have an answer
+		if resp.Status == ocsp.Unknown {
+			r.Errors = append(r.Errors, fmt.Sprintf("%s: the responder does not know the certificate", server))
+			continue
+		}
+
+		r.Status = ocspStatuses[resp.Status]
+		r.Source, r.URL = RevocationSourceOCSP, server
+		if resp.Status == ocsp.Revoked {
+			r.RevokedAt = resp.RevokedAt
+			r.Reason = revocationReasons[resp.RevocationReason]
+		}
+		return r
+	}
+
+	for _, dp := range leaf.CRLDistributionPoints {
+		entry, err := c.queryCRL(dp, leaf, issuer)
+		if err != nil {
+			r.Errors = append(r.Errors, fmt.Sprintf("%s: %v", dp, err))
+			continue
+		}
+
+		r.Status = ocspStatuses[ocsp.Good]
+		r.Source, r.URL = RevocationSourceCRL, dp
+		if entry != nil {
+			r.Status = ocspStatuses[ocsp.Revoked]
+			r.RevokedAt = entry.RevocationTime
+			r.Reason = revocationReasons[entry.ReasonCode]
+		}
+		return r
+	}
+
+	return r
+}
+
+// queryOCSP asks the OCSP responder at server for the status of leaf,
+// and checks that the response is current
+func (c *Certificate) queryOCSP(server string, leaf, issuer *x509.Certificate) (*ocsp.Response, error) {
+	body, err := ocsp.CreateRequest(leaf, issuer, &ocsp.RequestOptions{Hash: crypto.SHA1})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, server, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/ocsp-request")
+	req.Header.Set("Accept", "application/ocsp-response")
+
+	data, err := c.Network.Fetch(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := ocsp.ParseResponseForCert(data, leaf, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if resp.ThisUpdate.After(now) {
+		return nil, fmt.Errorf("the response's this update is in the future, at %s", resp.ThisUpdate)
+	}
+
+	if !resp.NextUpdate.IsZero() && now.After(resp.NextUpdate) {
+		return nil, fmt.Errorf("the response's next update was due %s", resp.NextUpdate)
+	}
+
+	return resp, nil
+}
+
+// queryCRL fetches the CRL at url, checks that it was issued by issuer
+// and is current, and returns the entry revoking leaf, if any
+func (c *Certificate) queryCRL(url string, leaf, issuer *x509.Certificate) (*x509.RevocationListEntry, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := c.Network.Fetch(req)
+	if err != nil {
+		return nil, err
+	}
+
+	crl, err := ParseCRL(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := crl.CheckSignatureFrom(issuer); err != nil {
+		return nil, err
+	}
+
+	if !crl.NextUpdate.IsZero() && time.Now().After(crl.NextUpdate) {
+		return nil, fmt.Errorf("the CRL's next update was due %s", crl.NextUpdate)
+	}
+
+	for i, entry := range crl.RevokedCertificateEntries {
+		if entry.SerialNumber.Cmp(leaf.SerialNumber) == 0 {
+			return &crl.RevokedCertificateEntries[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+// ParseCRL parses a DER or PEM encoded certificate revocation list
+func ParseCRL(data []byte) (*x509.RevocationList, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		if block.Type != "X509 CRL" {
+			return nil, fmt.Errorf("expected an X509 CRL PEM block, found %q", block.Type)
+		}
+		data = block.Bytes
+	}
+
+	crl, err := x509.ParseRevocationList(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse CRL: %w", err)
+	}
+
+	return crl, nil
+}
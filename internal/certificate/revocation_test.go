@@ -0,0 +1,287 @@
+package certificate_test
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/likexian/gokit/assert"
+	"github.com/mckern/spiry/internal/certificate"
+	"golang.org/x/crypto/ocsp"
+)
+
+// newRevocableLeaf issues a leaf certificate from the test PKI's
+// intermediate that names the given OCSP responder and CRL
+func newRevocableLeaf(t *testing.T, pki *testPKI, ocspURL string, crlURL string) testCert {
+	t.Helper()
+
+	now := time.Now().Truncate(time.Second)
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "localhost"},
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:   now.Add(-time.Hour),
+		NotAfter:    now.AddDate(0, 0, 90),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	if ocspURL != "" {
+		template.OCSPServer = []string{ocspURL}
+	}
+	if crlURL != "" {
+		template.CRLDistributionPoints = []string{crlURL}
+	}
+
+	return newTestCert(t, template, &pki.intermediate)
+}
+
+// serveOCSP answers OCSP requests for certificates issued by the test
+// PKI's intermediate with status, or with an error if status is negative
+func serveOCSP(t *testing.T, pki *testPKI, status int) string {
+	t.Helper()
+	return serveOCSPUntil(t, pki, status, time.Now().AddDate(0, 0, 3))
+}
+
+// serveOCSPUntil answers OCSP requests as serveOCSP does, with
+// responses whose next update is at nextUpdate
+func serveOCSPUntil(t *testing.T, pki *testPKI, status int, nextUpdate time.Time) string {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		req, err := ocsp.ParseRequest(body)
+		if err != nil || status < 0 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		now := time.Now().Truncate(time.Second)
+		resp, err := ocsp.CreateResponse(pki.intermediate.cert, pki.intermediate.cert, ocsp.Response{
+			Status:           status,
+			SerialNumber:     req.SerialNumber,
+			ThisUpdate:       nextUpdate.AddDate(0, 0, -4).Truncate(time.Second),
+			NextUpdate:       nextUpdate.Truncate(time.Second),
+			RevokedAt:        now.Add(-time.Hour),
+			RevocationReason: ocsp.KeyCompromise,
+		}, pki.intermediate.key)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		_, _ = w.Write(resp)
+	}))
+	t.Cleanup(server.Close)
+
+	return server.URL
+}
+
+// newCRL returns a PEM encoded CRL from the test PKI's
+// intermediate, revoking the given certificates
+func newCRL(t *testing.T, pki *testPKI, revoked ...*x509.Certificate) []byte {
+	t.Helper()
+
+	now := time.Now().Truncate(time.Second)
+	template := &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: now.Add(-time.Hour),
+		NextUpdate: now.AddDate(0, 0, 7),
+	}
+
+	for _, cert := range revoked {
+		template.RevokedCertificateEntries = append(template.RevokedCertificateEntries, x509.RevocationListEntry{
+			SerialNumber:   cert.SerialNumber,
+			RevocationTime: now.Add(-time.Hour),
+			ReasonCode:     ocsp.Superseded,
+		})
+	}
+
+	der, err := x509.CreateRevocationList(rand.Reader, template, pki.intermediate.cert, pki.intermediate.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der})
+}
+
+// serveBytes serves data at every path
+func serveBytes(t *testing.T, data []byte) string {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+
+	return server.URL
+}
+
+// serveLeaf serves leaf and the test PKI's intermediate over TLS
+func serveLeaf(t *testing.T, pki *testPKI, leaf testCert) string {
+	t.Helper()
+
+	return serveTLS(t, &tls.Config{Certificates: []tls.Certificate{{
+		Certificate: [][]byte{leaf.cert.Raw, pki.intermediate.cert.Raw},
+		PrivateKey:  leaf.key,
+	}}})
+}
+
+func TestRevocationOCSP(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		want    string
+		revoked bool
+	}{
+		{name: "good", status: ocsp.Good, want: "good"},
+		{name: "revoked", status: ocsp.Revoked, want: "revoked", revoked: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pki := newTestPKI(t)
+			leaf := newRevocableLeaf(t, pki, serveOCSP(t, pki, tt.status), "")
+
+			cert, err := certificate.New(serveLeaf(t, pki, leaf))
+			assert.Nil(t, err, "a host:port pair should parse")
+			cert.CheckRevocation = true
+
+			r, err := cert.Revocation()
+			assert.Nil(t, err, "the certificate should be retrieved")
+			assert.Equal(t, r.Status, tt.want)
+			assert.Equal(t, r.Source, certificate.RevocationSourceOCSP, "the status should come from OCSP")
+			assert.Equal(t, r.Revoked(), tt.revoked)
+
+			if tt.revoked {
+				assert.Equal(t, r.Reason, "keyCompromise", "the revocation reason should be reported")
+			}
+
+			fields, lines := certificate.ReportOf(r, rfc3339)
+			assert.Equal(t, fields["status"], tt.want, "the revocation status should be reported as a JSON field")
+			assert.Contains(t, lines[0], tt.want, "the revocation status should be reported in plain output")
+		})
+	}
+}
+
+func TestRevocationCRLFallback(t *testing.T) {
+	pki := newTestPKI(t)
+	ocspURL := serveOCSP(t, pki, -1)
+
+	// the leaf has to exist before the CRL revoking it, and the CRL's
+	// location has to be known before the leaf is issued
+	var crl []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(crl)
+	}))
+	t.Cleanup(server.Close)
+
+	leaf := newRevocableLeaf(t, pki, ocspURL, server.URL)
+	crl = newCRL(t, pki, leaf.cert)
+
+	cert, err := certificate.New(serveLeaf(t, pki, leaf))
+	assert.Nil(t, err, "a host:port pair should parse")
+
+	r, err := cert.Revocation()
+	assert.Nil(t, err, "the certificate should be retrieved")
+	assert.True(t, r.Revoked(), "a certificate on the CRL should be revoked")
+	assert.Equal(t, r.Source, certificate.RevocationSourceCRL, "the status should come from the CRL")
+	assert.Equal(t, r.Reason, "superseded", "the revocation reason should be reported")
+	assert.Equal(t, len(r.Errors), 1, "the failed OCSP request should be reported")
+}
+
+func TestRevocationStaleOCSP(t *testing.T) {
+	pki := newTestPKI(t)
+	ocspURL := serveOCSPUntil(t, pki, ocsp.Good, time.Now().Add(-time.Hour))
+	leaf := newRevocableLeaf(t, pki, ocspURL, serveBytes(t, newCRL(t, pki)))
+
+	cert, err := certificate.New(serveLeaf(t, pki, leaf))
+	assert.Nil(t, err, "a host:port pair should parse")
+
+	r, err := cert.Revocation()
+	assert.Nil(t, err, "the certificate should be retrieved")
+	assert.Equal(t, r.Source, certificate.RevocationSourceCRL, "a stale OCSP response should not be relied on")
+	assert.Equal(t, len(r.Errors), 1, "the stale OCSP response should be reported")
+	assert.Contains(t, r.Errors[0], "next update was due")
+}
+
+func TestRevocationCRLGood(t *testing.T) {
+	pki := newTestPKI(t)
+	leaf := newRevocableLeaf(t, pki, "", serveBytes(t, newCRL(t, pki)))
+
+	cert, err := certificate.New(serveLeaf(t, pki, leaf))
+	assert.Nil(t, err, "a host:port pair should parse")
+
+	r, err := cert.Revocation()
+	assert.Nil(t, err, "the certificate should be retrieved")
+	assert.Equal(t, r.Status, "good", "a certificate missing from the CRL should be good")
+}
+
+func TestRevocationUnknown(t *testing.T) {
+	pki := newTestPKI(t)
+	addr := serveTLS(t, &tls.Config{Certificates: []tls.Certificate{pki.tlsCertificate()}})
+
+	cert, err := certificate.New(addr)
+	assert.Nil(t, err, "a host:port pair should parse")
+
+	r, err := cert.Revocation()
+	assert.Nil(t, err, "the certificate should be retrieved")
+	assert.Equal(t, r.Status, "unknown", "a certificate without revocation sources should be unknown")
+	assert.Equal(t, len(r.Errors), 1, "the missing revocation sources should be reported")
+}
+
+func TestParseCRL(t *testing.T) {
+	pki := newTestPKI(t)
+	data := newCRL(t, pki, pki.leaf.cert)
+
+	crl, err := certificate.ParseCRL(data)
+	assert.Nil(t, err, "a PEM CRL should parse")
+	assert.Equal(t, len(crl.RevokedCertificateEntries), 1)
+
+	block, _ := pem.Decode(data)
+	_, err = certificate.ParseCRL(block.Bytes)
+	assert.Nil(t, err, "a DER CRL should parse")
+
+	_, err = certificate.ParseCRL([]byte("not a CRL"))
+	assert.NotNil(t, err, "garbage should not parse")
+}
+
+func TestRevocationChasedIssuer(t *testing.T) {
+	pki := newAIAPKI(t)
+	leaf := newTestCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             pki.leaf.cert.NotBefore,
+		NotAfter:              pki.leaf.cert.NotAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		OCSPServer:            []string{serveOCSP(t, pki, ocsp.Good)},
+		IssuingCertificateURL: pki.leaf.cert.IssuingCertificateURL,
+	}, &pki.intermediate)
+
+	// only the leaf is presented
+	addr := serveTLS(t, &tls.Config{Certificates: []tls.Certificate{{
+		Certificate: [][]byte{leaf.cert.Raw},
+		PrivateKey:  leaf.key,
+	}}})
+
+	cert, err := certificate.New(addr)
+	assert.Nil(t, err, "a host:port pair should parse")
+	cert.ChaseAIA = true
+	cert.CheckRevocation = true
+
+	r, err := cert.Revocation()
+	assert.Nil(t, err, "the certificate should be retrieved")
+	assert.Equal(t, len(r.Errors), 0, "the issuer fetched from AIA should be used")
+	assert.Equal(t, r.Status, "good")
+}
@@ -0,0 +1,130 @@
+package certificate
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// ocspStatuses names the certificate statuses an OCSP response can give
+var ocspStatuses = map[int]string{
+	ocsp.Good:    "good",
+	ocsp.Revoked: "revoked",
+	ocsp.Unknown: "unknown",
+}
+
+// Session describes the TLS session negotiated with the server
+type Session struct {
+	Version     string
+	CipherSuite string
+	// ALPN is the application protocol agreed on, if any
+	ALPN string
+	// OCSPStapled is set when the server stapled an OCSP response
+	// to the handshake; the rest of the OCSP fields describe it.
+	OCSPStapled    bool
+	OCSPStatus     string
+	OCSPThisUpdate time.Time
+	OCSPNextUpdate time.Time
+	// OCSPError explains why a stapled response could not be parsed
+	OCSPError string
+}
+
+// Session describes the TLS session negotiated with the server
+// when its certificate was retrieved
+func (c *Certificate) Session() (*Session, error) {
+	if _, err := c.Expiry(); err != nil {
+		return nil, err
+	}
+
+	return &c.session, nil
+}
+
+// setState records the certificate chain and session
+// from a completed handshake
+func (c *Certificate) setState(state tls.ConnectionState) {
+	c.raw = state.PeerCertificates[0]
+	c.chain = state.PeerCertificates
+	c.session = sessionOf(state)
+}
+
+// sessionOf describes the TLS session in state
+func sessionOf(state tls.ConnectionState) Session {
+	s := Session{
+		Version:     tls.VersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+		ALPN:        state.NegotiatedProtocol,
+		OCSPStapled: len(state.OCSPResponse) > 0,
+	}
+
+	if !s.OCSPStapled {
+		return s
+	}
+
+	// the staple is signed by the leaf's issuer, or by a responder
+	// certificate that the issuer delegated to and that is included
+	// in the response
+	var issuer *x509.Certificate
+	if len(state.PeerCertificates) > 1 {
+		issuer = state.PeerCertificates[1]
+	}
+
+	resp, err := ocsp.ParseResponse(state.OCSPResponse, issuer)
+	if err != nil {
+		s.OCSPError = err.Error()
+		return s
+	}
+
+	s.OCSPStatus = ocspStatuses[resp.Status]
+	s.OCSPThisUpdate = resp.ThisUpdate
+	s.OCSPNextUpdate = resp.NextUpdate
+	return s
+}
+
+// report returns s as JSON fields and lines of plain output,
+// using format for any times
+func (s *Session) report(format func(time.Time) string) (fields map[string]any, lines []string) {
+	fields = map[string]any{
+		"version":     s.Version,
+		"cipherSuite": s.CipherSuite,
+		"alpn":        s.ALPN,
+		"ocspStapled": s.OCSPStapled,
+	}
+
+	line := func(label string, value string) {
+		if value != "" {
+			lines = append(lines, fmt.Sprintf("  %-20s %s", label+":", value))
+		}
+	}
+
+	line("TLS version", s.Version)
+	line("cipher suite", s.CipherSuite)
+	line("ALPN protocol", s.ALPN)
+
+	if !s.OCSPStapled {
+		line("OCSP staple", "none")
+		return
+	}
+
+	if s.OCSPError != "" {
+		fields["ocspError"] = s.OCSPError
+		line("OCSP staple", "unparseable: "+s.OCSPError)
+		return
+	}
+
+	fields["ocspStatus"] = s.OCSPStatus
+	fields["ocspThisUpdate"] = format(s.OCSPThisUpdate)
+	line("OCSP staple", s.OCSPStatus)
+	line("OCSP this update", format(s.OCSPThisUpdate))
+
+	// a response without a next update is only good until
+	// a newer one is available, which is always
+	if !s.OCSPNextUpdate.IsZero() {
+		fields["ocspNextUpdate"] = format(s.OCSPNextUpdate)
+		line("OCSP next update", format(s.OCSPNextUpdate))
+	}
+
+	return
+}
@@ -0,0 +1,89 @@
+package certificate_test
+
+import (
+	"crypto/tls"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/likexian/gokit/assert"
+	"github.com/mckern/spiry/internal/certificate"
+	"golang.org/x/crypto/ocsp"
+)
+
+// newOCSPStaple returns an OCSP response for the test PKI's leaf,
+// signed by its issuer and good until nextUpdate
+func newOCSPStaple(t *testing.T, pki *testPKI, nextUpdate time.Time) []byte {
+	t.Helper()
+
+	now := time.Now().Truncate(time.Second)
+	staple, err := ocsp.CreateResponse(pki.intermediate.cert, pki.intermediate.cert, ocsp.Response{
+		Status:       ocsp.Good,
+		SerialNumber: pki.leaf.cert.SerialNumber,
+		ThisUpdate:   now.Add(-time.Hour),
+		NextUpdate:   nextUpdate,
+	}, pki.intermediate.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return staple
+}
+
+func TestSession(t *testing.T) {
+	pki := newTestPKI(t)
+	addr := serveTLS(t, &tls.Config{
+		Certificates: []tls.Certificate{pki.tlsCertificate()},
+		NextProtos:   []string{"h2", "http/1.1"},
+	})
+
+	cert, err := certificate.New(addr)
+	assert.Nil(t, err, "a host:port pair should parse")
+	cert.ALPN = "http/1.1"
+
+	session, err := cert.Session()
+	assert.Nil(t, err, "the certificate should be retrieved")
+	assert.Equal(t, session.Version, "TLS 1.3", "the negotiated TLS version should be reported")
+	assert.NotEqual(t, session.CipherSuite, "", "the negotiated cipher suite should be reported")
+	assert.Equal(t, session.ALPN, "http/1.1", "the negotiated application protocol should be reported")
+	assert.False(t, session.OCSPStapled, "a missing OCSP staple should be reported")
+
+	fields, lines := certificate.ReportOf(session, rfc3339)
+	assert.Equal(t, fields["alpn"], "http/1.1", "the session should be reported as a JSON field")
+	assert.Contains(t, strings.Join(lines, "\n"), "TLS 1.3", "the session should be reported in plain output")
+}
+
+func TestOCSPStaple(t *testing.T) {
+	pki := newTestPKI(t)
+	nextUpdate := time.Now().UTC().Truncate(time.Second).AddDate(0, 0, 3)
+
+	serving := pki.tlsCertificate()
+	serving.OCSPStaple = newOCSPStaple(t, pki, nextUpdate)
+	addr := serveTLS(t, &tls.Config{Certificates: []tls.Certificate{serving}})
+
+	cert, err := certificate.New(addr)
+	assert.Nil(t, err, "a host:port pair should parse")
+	cert.Detailed = true
+
+	session, err := cert.Session()
+	assert.Nil(t, err, "the certificate should be retrieved")
+	assert.True(t, session.OCSPStapled, "a stapled OCSP response should be reported")
+	assert.Equal(t, session.OCSPStatus, "good", "the staple's status should be reported")
+	assert.Equal(t, session.OCSPNextUpdate, nextUpdate, "the staple's next update should be reported")
+
+	expiry, err := cert.Expiry()
+	assert.Nil(t, err, "the certificate should be retrieved")
+	assert.Equal(t, expiry, pki.leaf.cert.NotAfter, "the staple should not be an expiry signal unless asked")
+
+	fields, lines := certificate.ReportOf(session, rfc3339)
+	assert.Equal(t, fields["ocspNextUpdate"], nextUpdate.Format(time.RFC3339))
+	assert.Contains(t, lines[len(lines)-1], "OCSP next update", "the staple should be described with details")
+
+	cert, err = certificate.New(addr)
+	assert.Nil(t, err, "a host:port pair should parse")
+	cert.StapleExpiry = true
+
+	expiry, err = cert.Expiry()
+	assert.Nil(t, err, "the certificate should be retrieved")
+	assert.Equal(t, expiry, nextUpdate, "an earlier staple next update should be used as the expiry")
+}
@@ -0,0 +1,193 @@
+package certificate
+
+import (
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+)
+
+// protocol describes a plaintext protocol that can be upgraded
+// to TLS in-band, usually by way of a STARTTLS command.
+type protocol struct {
+	// port is used when an address does not specify one
+	port string
+	// upgrade negotiates the switch to TLS over conn, leaving
+	// conn ready for a TLS client handshake
+	upgrade func(conn net.Conn, name string) error
+}
+
+// protocols maps the names accepted by --starttls, and the URL schemes
+// accepted by parseAsURL, to the protocol used to upgrade to TLS.
+var protocols = map[string]protocol{
+	"smtp":  {port: "25", upgrade: startSMTP},
+	"imap":  {port: "143", upgrade: startIMAP},
+	"pop3":  {port: "110", upgrade: startPOP3},
+	"sieve": {port: "4190", upgrade: startSieve},
+
+	"postgres":   {port: "5432", upgrade: startPostgres},
+	"postgresql": {port: "5432", upgrade: startPostgres},
+	"mysql":      {port: "3306", upgrade: startMySQL},
+
+	"ldap":        {port: "389", upgrade: startLDAP},
+	"ftp":         {port: "21", upgrade: startFTP},
+	"xmpp":        {port: "5222", upgrade: startXMPPClient},
+	"xmpp-server": {port: "5269", upgrade: startXMPPServer},
+}
+
+// protocolFromScheme returns the name of the protocol matching the
+// URL scheme of addr, or an empty string if there isn't one.
+func protocolFromScheme(addr string) string {
+	scheme, _, found := strings.Cut(addr, "://")
+	if !found {
+		return ""
+	}
+
+	scheme = strings.ToLower(scheme)
+	if _, ok := protocols[scheme]; !ok {
+		return ""
+	}
+
+	return scheme
+}
+
+// startSMTP issues STARTTLS after greeting an SMTP server (RFC 3207)
+func startSMTP(conn net.Conn, name string) error {
+	text := textproto.NewConn(conn)
+
+	if _, _, err := text.ReadResponse(220); err != nil {
+		return err
+	}
+
+	if err := text.PrintfLine("EHLO spiry"); err != nil {
+		return err
+	}
+
+	_, msg, err := text.ReadResponse(250)
+	if err != nil {
+		return err
+	}
+
+	if !strings.Contains(strings.ToUpper(msg), "STARTTLS") {
+		return fmt.Errorf("SMTP server for %v does not offer STARTTLS", name)
+	}
+
+	if err := text.PrintfLine("STARTTLS"); err != nil {
+		return err
+	}
+
+	_, _, err = text.ReadResponse(220)
+	return err
+}
+
+// startIMAP issues a tagged STARTTLS command to an IMAP server (RFC 2595)
+func startIMAP(conn net.Conn, _ string) error {
+	text := textproto.NewConn(conn)
+
+	greeting, err := text.ReadLine()
+	if err != nil {
+		return err
+	}
+
+	if !strings.HasPrefix(greeting, "* OK") {
+		return fmt.Errorf("unexpected IMAP greeting: %q", greeting)
+	}
+
+	if err := text.PrintfLine("spiry STARTTLS"); err != nil {
+		return err
+	}
+
+	// skip any untagged responses while waiting for a reply
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return err
+		}
+
+		if status, ok := strings.CutPrefix(line, "spiry "); ok {
+			if !strings.HasPrefix(status, "OK") {
+				return fmt.Errorf("IMAP server refused STARTTLS: %q", status)
+			}
+			return nil
+		}
+	}
+}
+
+// startPOP3 issues STLS to a POP3 server (RFC 2595)
+func startPOP3(conn net.Conn, _ string) error {
+	text := textproto.NewConn(conn)
+
+	greeting, err := text.ReadLine()
+	if err != nil {
+		return err
+	}
+
+	if !strings.HasPrefix(greeting, "+OK") {
+		return fmt.Errorf("unexpected POP3 greeting: %q", greeting)
+	}
+
+	if err := text.PrintfLine("STLS"); err != nil {
+		return err
+	}
+
+	reply, err := text.ReadLine()
+	if err != nil {
+		return err
+	}
+
+	if !strings.HasPrefix(reply, "+OK") {
+		return fmt.Errorf("POP3 server refused STLS: %q", reply)
+	}
+
+	return nil
+}
+
+// startSieve issues STARTTLS to a ManageSieve server (RFC 5804)
+func startSieve(conn net.Conn, _ string) error {
+	text := textproto.NewConn(conn)
+
+	// the greeting is a list of capabilities, terminated by an OK response
+	if err := readSieveResponse(text); err != nil {
+		return err
+	}
+
+	if err := text.PrintfLine("STARTTLS"); err != nil {
+		return err
+	}
+
+	return readSieveResponse(text)
+}
+
+// readSieveResponse reads lines from a ManageSieve server until it
+// sends a response code, returning an error unless the response is OK
+func readSieveResponse(text *textproto.Conn) error {
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return err
+		}
+
+		switch word, _, _ := strings.Cut(strings.ToUpper(line), " "); word {
+		case "OK":
+			return nil
+		case "NO", "BYE":
+			return fmt.Errorf("ManageSieve server refused STARTTLS: %q", line)
+		}
+	}
+}
+
+// startFTP issues AUTH TLS to an FTP server (RFC 4217)
+func startFTP(conn net.Conn, _ string) error {
+	text := textproto.NewConn(conn)
+
+	if _, _, err := text.ReadResponse(220); err != nil {
+		return err
+	}
+
+	if err := text.PrintfLine("AUTH TLS"); err != nil {
+		return err
+	}
+
+	_, _, err := text.ReadResponse(234)
+	return err
+}
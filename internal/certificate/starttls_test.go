@@ -0,0 +1,172 @@
+package certificate_test
+
+import (
+	"crypto/tls"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/likexian/gokit/assert"
+	"github.com/mckern/spiry/internal/certificate"
+)
+
+// dialogue plays the server side of a plaintext protocol
+// up to the point where a TLS handshake should begin
+type dialogue func(conn net.Conn) error
+
+var mailDialogues = map[string]dialogue{
+	"smtp": func(conn net.Conn) error {
+		text := textproto.NewConn(conn)
+		_ = text.PrintfLine("220 localhost ESMTP fake")
+		if _, err := text.ReadLine(); err != nil {
+			return err
+		}
+		_ = text.PrintfLine("250-localhost")
+		_ = text.PrintfLine("250 STARTTLS")
+		if _, err := text.ReadLine(); err != nil {
+			return err
+		}
+		return text.PrintfLine("220 ready to start TLS")
+	},
+	"imap": func(conn net.Conn) error {
+		text := textproto.NewConn(conn)
+		_ = text.PrintfLine("* OK IMAP4rev1 fake ready")
+		line, err := text.ReadLine()
+		if err != nil {
+			return err
+		}
+		tag, _, _ := strings.Cut(line, " ")
+		_ = text.PrintfLine("* CAPABILITY IMAP4rev1 STARTTLS")
+		return text.PrintfLine("%s OK begin TLS negotiation now", tag)
+	},
+	"pop3": func(conn net.Conn) error {
+		text := textproto.NewConn(conn)
+		_ = text.PrintfLine("+OK POP3 fake ready")
+		if _, err := text.ReadLine(); err != nil {
+			return err
+		}
+		return text.PrintfLine("+OK begin TLS negotiation")
+	},
+	"sieve": func(conn net.Conn) error {
+		text := textproto.NewConn(conn)
+		_ = text.PrintfLine(`"IMPLEMENTATION" "fake"`)
+		_ = text.PrintfLine(`"STARTTLS"`)
+		_ = text.PrintfLine(`OK`)
+		if _, err := text.ReadLine(); err != nil {
+			return err
+		}
+		return text.PrintfLine(`OK "begin TLS negotiation now"`)
+	},
+}
+
+// serveStartTLS accepts plaintext connections on a loopback address,
+// plays d and then completes a TLS handshake over the same connection.
+func serveStartTLS(t *testing.T, config *tls.Config, d dialogue) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer func() { _ = conn.Close() }()
+				if err := d(conn); err != nil {
+					return
+				}
+				_ = tls.Server(conn, config).Handshake()
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func TestStartTLS(t *testing.T) {
+	pki := newTestPKI(t)
+	config := &tls.Config{Certificates: []tls.Certificate{pki.tlsCertificate()}}
+
+	for proto, d := range mailDialogues {
+		t.Run(proto, func(t *testing.T) {
+			addr := serveStartTLS(t, config, d)
+			_, port, _ := net.SplitHostPort(addr)
+
+			cert, err := certificate.New(proto + "://localhost:" + port)
+			assert.Nil(t, err, "a URL with a STARTTLS scheme should parse")
+			assert.Equal(t, cert.StartTLS, proto, "the URL scheme should select the STARTTLS protocol")
+
+			expiry, err := cert.Expiry()
+			assert.Nil(t, err, "a certificate should be retrieved after STARTTLS")
+			assert.True(t, expiry.Equal(pki.leaf.cert.NotAfter), "the leaf certificate should be retrieved")
+		})
+	}
+}
+
+func TestStartTLSByName(t *testing.T) {
+	pki := newTestPKI(t)
+	config := &tls.Config{Certificates: []tls.Certificate{pki.tlsCertificate()}}
+	addr := serveStartTLS(t, config, mailDialogues["pop3"])
+
+	cert, err := certificate.New(addr)
+	assert.Nil(t, err, "a loopback address should parse")
+	assert.Equal(t, cert.StartTLS, "", "a bare address should not use STARTTLS")
+
+	cert.StartTLS = "pop3"
+	_, err = cert.Expiry()
+	assert.Nil(t, err, "a certificate should be retrieved after STARTTLS")
+}
+
+func TestStartTLSNotOffered(t *testing.T) {
+	addr := serveStartTLS(t, &tls.Config{}, func(conn net.Conn) error {
+		text := textproto.NewConn(conn)
+		_ = text.PrintfLine("220 localhost ESMTP fake")
+		if _, err := text.ReadLine(); err != nil {
+			return err
+		}
+		return text.PrintfLine("250 localhost")
+	})
+
+	cert, _ := certificate.New(addr)
+	cert.StartTLS = "smtp"
+	_, err := cert.Expiry()
+	assert.NotNil(t, err, "an SMTP server without STARTTLS should raise an error")
+}
+
+func TestStartTLSDefaultPort(t *testing.T) {
+	cert, err := certificate.New("sieve://mail.example.com")
+	assert.Nil(t, err, "a URL without a port should use the protocol's well-known port")
+	assert.Equal(t, cert.StartTLS, "sieve", "the URL scheme should select the STARTTLS protocol")
+}
+
+func TestFTPAuthTLS(t *testing.T) {
+	pki := newTestPKI(t)
+	config := &tls.Config{Certificates: []tls.Certificate{pki.tlsCertificate()}}
+
+	addr := serveStartTLS(t, config, func(conn net.Conn) error {
+		text := textproto.NewConn(conn)
+		_ = text.PrintfLine("220-fake FTP server")
+		_ = text.PrintfLine("220 ready")
+		if _, err := text.ReadLine(); err != nil {
+			return err
+		}
+		return text.PrintfLine("234 AUTH TLS successful")
+	})
+	_, port, _ := net.SplitHostPort(addr)
+
+	cert, err := certificate.New("ftp://localhost:" + port)
+	assert.Nil(t, err, "an ftp:// URL should parse")
+	assert.Equal(t, cert.StartTLS, "ftp", "the URL scheme should select FTP AUTH TLS")
+
+	expiry, err := cert.Expiry()
+	assert.Nil(t, err, "a certificate should be retrieved after AUTH TLS")
+	assert.True(t, expiry.Equal(pki.leaf.cert.NotAfter), "the leaf certificate should be retrieved")
+}
@@ -0,0 +1,241 @@
+package certificate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// events reported by tracking a server's certificate between checks
+const (
+	TrackFirstSeen = "first-seen"
+	TrackUnchanged = "unchanged"
+	TrackChanged   = "changed"
+	TrackRenewed   = "renewed"
+	TrackRegressed = "regressed"
+)
+
+// maxTracked is how many distinct certificates are remembered
+// for each server before the oldest is forgotten
+const maxTracked = 10
+
+// trackedServer is the state file of a single server
+type trackedServer struct {
+	Key          string        `json:"key"`
+	Certificates []Observation `json:"certificates"`
+}
+
+// Observation is a leaf certificate seen when checking a server
+type Observation struct {
+	Fingerprint  string    `json:"sha256Fingerprint"`
+	SerialNumber string    `json:"serialNumber"`
+	Expiry       time.Time `json:"expiry"`
+	FirstSeen    time.Time `json:"firstSeen"`
+	LastSeen     time.Time `json:"lastSeen"`
+}
+
+// Tracking is how the leaf certificate served by a server compares
+// to the one served the last time the server was checked
+type Tracking struct {
+	// Key identifies the server by its SNI and address
+	Key   string
+	Event string
+	// Previous is the certificate served last time, which is nil
+	// when the server is seen for the first time
+	Previous *Observation
+	Current  Observation
+}
+
+// DefaultStateDir returns where certificates are tracked
+// between checks when no StateDir is given
+func DefaultStateDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "spiry", "certificates"), nil
+}
+
+// Track compares the leaf certificate to the one served the last time
+// the server was checked, according to its state file in StateDir, and
+// records it there. Every server has a state file of its own, so that
+// checks of different servers can run at the same time.
+func (c *Certificate) Track() (*Tracking, error) {
+	if c.tracking != nil {
+		return c.tracking, nil
+	}
+
+	if _, err := c.Expiry(); err != nil {
+		return nil, err
+	}
+
+	if c.StateDir == "" {
+		return nil, errors.New("no state directory given")
+	}
+
+	key := c.Name() + "@" + c.addr
+	path := stateFile(c.StateDir, key)
+	state, err := readState(path, key)
+	if err != nil {
+		return nil, err
+	}
+
+	d := DetailsOf(c.raw)
+	now := time.Now()
+	t := &Tracking{
+		Key: key,
+		Current: Observation{
+			Fingerprint:  d.Fingerprint,
+			SerialNumber: d.SerialNumber,
+			Expiry:       c.raw.NotAfter,
+			FirstSeen:    now,
+			LastSeen:     now,
+		},
+	}
+
+	seen := state.Certificates
+	t.Event = TrackFirstSeen
+	if len(seen) > 0 {
+		previous := seen[len(seen)-1]
+		t.Previous = &previous
+		t.Event = trackEvent(previous, t.Current)
+	}
+
+	if t.Event == TrackUnchanged {
+		t.Current.FirstSeen = t.Previous.FirstSeen
+		seen[len(seen)-1] = t.Current
+	} else {
+		seen = append(seen, t.Current)
+	}
+	state.Certificates = seen[max(0, len(seen)-maxTracked):]
+
+	if err := writeState(path, state); err != nil {
+		return nil, err
+	}
+
+	c.tracking = t
+	return c.tracking, nil
+}
+
+// trackEvent describes how current differs from previous
+func trackEvent(previous, current Observation) string {
+	switch {
+	case previous.Fingerprint == current.Fingerprint:
+		return TrackUnchanged
+	case current.Expiry.After(previous.Expiry):
+		return TrackRenewed
+	case current.Expiry.Before(previous.Expiry):
+		return TrackRegressed
+	default:
+		return TrackChanged
+	}
+}
+
+// stateFile returns the path of the state file in dir
+// of the server identified by key
+func stateFile(dir string, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(dir, hex.EncodeToString(sum[:16])+".json")
+}
+
+// readState reads the certificates previously seen for the server
+// identified by key from the state file at path, which may not exist yet
+func readState(path string, key string) (*trackedServer, error) {
+	state := &trackedServer{Key: key}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		slog.Debug("state file does not exist yet", "path", path)
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("unable to read state file %v: %w", path, err)
+	}
+
+	if state.Key != key {
+		return nil, fmt.Errorf("state file %v belongs to %v, not %v", path, state.Key, key)
+	}
+
+	return state, nil
+}
+
+// writeState replaces the state file at path, writing it to a temporary
+// file first so that neither an interrupted write nor a concurrent
+// reader can see it truncated
+func writeState(path string, state *trackedServer) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// report returns t as JSON fields and lines of plain output
+func (t *Tracking) report(format func(time.Time) string) (fields map[string]any, lines []string) {
+	fields = map[string]any{
+		"key":               t.Key,
+		"event":             t.Event,
+		"sha256Fingerprint": t.Current.Fingerprint,
+		"serialNumber":      t.Current.SerialNumber,
+		"expiry":            format(t.Current.Expiry),
+		"firstSeen":         format(t.Current.FirstSeen),
+	}
+
+	if t.Previous != nil {
+		fields["previous"] = map[string]any{
+			"sha256Fingerprint": t.Previous.Fingerprint,
+			"serialNumber":      t.Previous.SerialNumber,
+			"expiry":            format(t.Previous.Expiry),
+			"firstSeen":         format(t.Previous.FirstSeen),
+			"lastSeen":          format(t.Previous.LastSeen),
+		}
+	}
+
+	var line string
+	switch t.Event {
+	case TrackFirstSeen:
+		line = "first seen"
+	case TrackUnchanged:
+		line = "unchanged since " + format(t.Current.FirstSeen)
+	case TrackRenewed:
+		line = fmt.Sprintf("renewed, expiry %s -> %s", format(t.Previous.Expiry), format(t.Current.Expiry))
+	case TrackRegressed:
+		line = fmt.Sprintf("REGRESSED, expiry %s -> %s", format(t.Previous.Expiry), format(t.Current.Expiry))
+	default:
+		line = fmt.Sprintf("CHANGED, sha256 %s -> %s", t.Previous.Fingerprint, t.Current.Fingerprint)
+	}
+	lines = append(lines, fmt.Sprintf("  tracked %s: %s", t.Key, line))
+
+	return
+}
@@ -0,0 +1,148 @@
+package certificate_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/likexian/gokit/assert"
+	"github.com/mckern/spiry/internal/certificate"
+)
+
+func TestTrack(t *testing.T) {
+	pki := newTestPKI(t)
+	leafExpiring := func(notAfter time.Time) testCert {
+		return newTestCert(t, &x509.Certificate{
+			Subject:     pkix.Name{CommonName: "localhost"},
+			DNSNames:    []string{"localhost"},
+			NotBefore:   time.Now().Add(-time.Hour),
+			NotAfter:    notAfter,
+			KeyUsage:    x509.KeyUsageDigitalSignature,
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}, &pki.intermediate)
+	}
+
+	original := pki.leaf
+	renewed := leafExpiring(original.cert.NotAfter.AddDate(0, 0, 30))
+	replaced := leafExpiring(original.cert.NotAfter)
+
+	// the server's certificate is swapped between checks
+	var served testCert
+	addr := serveTLS(t, &tls.Config{GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return &tls.Certificate{
+			Certificate: [][]byte{served.cert.Raw, pki.intermediate.cert.Raw},
+			PrivateKey:  served.key,
+		}, nil
+	}})
+
+	stateDir := filepath.Join(t.TempDir(), "spiry")
+	checks := []struct {
+		served testCert
+		event  string
+	}{
+		{served: original, event: certificate.TrackFirstSeen},
+		{served: original, event: certificate.TrackUnchanged},
+		{served: renewed, event: certificate.TrackRenewed},
+		{served: original, event: certificate.TrackRegressed},
+		{served: replaced, event: certificate.TrackChanged},
+	}
+
+	var previous *x509.Certificate
+	for _, check := range checks {
+		served = check.served
+		cert, err := certificate.NewWithName("localhost", addr)
+		assert.Nil(t, err, "the address should parse")
+		cert.StateDir = stateDir
+
+		tracking, err := cert.Track()
+		assert.Nil(t, err, "the certificate should be tracked")
+		assert.Equal(t, tracking.Event, check.event)
+		assert.Equal(t, tracking.Current.Expiry, check.served.cert.NotAfter)
+		if previous == nil {
+			assert.True(t, tracking.Previous == nil, "a certificate seen for the first time has no predecessor")
+		} else {
+			assert.Equal(t, tracking.Previous.Expiry, previous.NotAfter)
+		}
+		previous = check.served.cert
+
+		fields, lines := certificate.ReportOf(tracking, rfc3339)
+		assert.Equal(t, fields["event"], check.event, "tracking should be reported as a JSON field")
+		assert.Contains(t, lines[0], tracking.Key, "tracking should be reported in plain output")
+	}
+
+	files, err := filepath.Glob(filepath.Join(stateDir, "*"))
+	assert.Nil(t, err)
+	assert.Equal(t, len(files), 1, "the server should have a single state file")
+
+	data, err := os.ReadFile(files[0])
+	assert.Nil(t, err, "the state file should be written")
+
+	var state struct {
+		Key          string
+		Certificates []certificate.Observation
+	}
+	assert.Nil(t, json.Unmarshal(data, &state), "the state file should be JSON")
+	assert.Equal(t, state.Key, "localhost@"+addr)
+	assert.Equal(t, len(state.Certificates), 4)
+}
+
+func TestTrackConcurrently(t *testing.T) {
+	stateDir := t.TempDir()
+	addrs := make([]string, 8)
+	for i := range addrs {
+		pki := newTestPKI(t)
+		addrs[i] = serveTLS(t, &tls.Config{Certificates: []tls.Certificate{pki.tlsCertificate()}})
+	}
+
+	var wg sync.WaitGroup
+	for _, addr := range addrs {
+		wg.Go(func() {
+			cert, err := certificate.New(addr)
+			assert.Nil(t, err, "the address should parse")
+			cert.StateDir = stateDir
+
+			_, err = cert.Track()
+			assert.Nil(t, err, "the certificate should be tracked")
+		})
+	}
+	wg.Wait()
+
+	for _, addr := range addrs {
+		cert, _ := certificate.New(addr)
+		cert.StateDir = stateDir
+
+		tracking, err := cert.Track()
+		assert.Nil(t, err, "the certificate should be tracked")
+		assert.Equal(t, tracking.Event, certificate.TrackUnchanged, "no server's first check should be lost")
+	}
+}
+
+func TestTrackCorruptStateFile(t *testing.T) {
+	pki := newTestPKI(t)
+	addr := serveTLS(t, &tls.Config{Certificates: []tls.Certificate{pki.tlsCertificate()}})
+
+	stateDir := t.TempDir()
+	cert, err := certificate.New(addr)
+	assert.Nil(t, err, "the address should parse")
+	cert.StateDir = stateDir
+
+	_, err = cert.Track()
+	assert.Nil(t, err, "the certificate should be tracked")
+
+	files, _ := filepath.Glob(filepath.Join(stateDir, "*"))
+	if err := os.WriteFile(files[0], []byte("not JSON"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cert, _ = certificate.New(addr)
+	cert.StateDir = stateDir
+
+	_, err = cert.Track()
+	assert.NotNil(t, err, "a corrupt state file should not be overwritten")
+}
@@ -0,0 +1,132 @@
+package certificate
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// kinds of problems found when verifying a certificate chain
+const (
+	ProblemHostname    = "hostname"
+	ProblemAuthority   = "authority"
+	ProblemExpired     = "expired"
+	ProblemNotYetValid = "notYetValid"
+	ProblemInvalid     = "invalid"
+)
+
+// Problem is a single reason that a certificate chain failed verification
+type Problem struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// Verification is the outcome of verifying a certificate chain
+// against a set of trusted roots and the name it was requested for
+type Verification struct {
+	// Verified is true when no problems were found
+	Verified bool      `json:"verified"`
+	Problems []Problem `json:"problems"`
+}
+
+// describe returns lines of plain output for the verification
+func (v *Verification) describe() []string {
+	if v.Verified {
+		return []string{"  verified"}
+	}
+
+	lines := make([]string, 0, len(v.Problems))
+	for _, p := range v.Problems {
+		lines = append(lines, fmt.Sprintf("  verification failed (%s): %s", p.Kind, p.Message))
+	}
+
+	return lines
+}
+
+func (v *Verification) add(kind string, err error) {
+	v.Problems = append(v.Problems, Problem{Kind: kind, Message: err.Error()})
+}
+
+// Verify checks the certificate chain presented by the server, along
+// with any intermediates fetched by ChaseAIA, against Roots, or the
+// system roots if Roots is nil, and checks that the leaf certificate
+// is valid for the requested name. Every problem found is reported,
+// rather than only the first.
+func (c *Certificate) Verify() (*Verification, error) {
+	if c.verification != nil {
+		return c.verification, nil
+	}
+
+	if _, err := c.PeerCertificates(); err != nil {
+		return nil, err
+	}
+
+	c.verification = verifyChain(c.fullChain(), c.Name(), c.Roots, time.Now())
+	return c.verification, nil
+}
+
+func verifyChain(chain []*x509.Certificate, name string, roots *x509.CertPool, now time.Time) *Verification {
+	v := &Verification{Problems: []Problem{}}
+	leaf := chain[0]
+
+	if err := leaf.VerifyHostname(name); err != nil {
+		v.add(ProblemHostname, err)
+	}
+
+	// every certificate presented is checked, so that an expired
+	// intermediate is named even if another path could be built
+	for _, cert := range chain {
+		if now.After(cert.NotAfter) {
+			v.add(ProblemExpired, fmt.Errorf("certificate %q expired at %s",
+				cert.Subject, cert.NotAfter.Format(time.RFC3339)))
+		} else if now.Before(cert.NotBefore) {
+			v.add(ProblemNotYetValid, fmt.Errorf("certificate %q is not valid until %s",
+				cert.Subject, cert.NotBefore.Format(time.RFC3339)))
+		}
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+
+	var unknownAuthority x509.UnknownAuthorityError
+	var invalid x509.CertificateInvalidError
+	switch {
+	case err == nil:
+	case errors.As(err, &unknownAuthority):
+		v.add(ProblemAuthority, err)
+	case errors.As(err, &invalid) && invalid.Reason == x509.Expired:
+		// already reported for the certificate in question
+	default:
+		v.add(ProblemInvalid, err)
+	}
+
+	v.Verified = len(v.Problems) == 0
+	return v
+}
+
+// LoadCAFile returns a pool of the PEM-encoded certificates in path,
+// for use as Roots
+func LoadCAFile(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM certificates found in CA file %v", path)
+	}
+
+	return pool, nil
+}
@@ -0,0 +1,147 @@
+package certificate_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/likexian/gokit/assert"
+	"github.com/mckern/spiry/internal/certificate"
+)
+
+// problemKinds returns the kind of each problem found by verification
+func problemKinds(v *certificate.Verification) (kinds []string) {
+	for _, p := range v.Problems {
+		kinds = append(kinds, p.Kind)
+	}
+	return
+}
+
+func TestVerify(t *testing.T) {
+	pki := newTestPKI(t)
+	addr := serveTLS(t, &tls.Config{Certificates: []tls.Certificate{pki.tlsCertificate()}})
+
+	roots := x509.NewCertPool()
+	roots.AddCert(pki.root.cert)
+
+	var verifyTests = []struct {
+		name      string
+		certName  string
+		roots     *x509.CertPool
+		wantKinds []string
+	}{
+		{name: "a chain issued by a trusted root verifies", roots: roots},
+		{name: "a chain issued by an unknown root fails", wantKinds: []string{certificate.ProblemAuthority}},
+		{name: "a name not covered by the certificate fails",
+			certName:  "example.com",
+			roots:     roots,
+			wantKinds: []string{certificate.ProblemHostname}},
+	}
+
+	for _, tt := range verifyTests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert, err := certificate.New(addr)
+			if tt.certName != "" {
+				cert, err = certificate.NewWithName(tt.certName, addr)
+			}
+			assert.Nil(t, err, "a loopback address should parse")
+			cert.Roots = tt.roots
+
+			v, err := cert.Verify()
+			assert.Nil(t, err, "a certificate should be retrieved")
+			assert.Equal(t, v.Verified, len(tt.wantKinds) == 0, "verification should only pass without problems")
+			assert.Equal(t, problemKinds(v), tt.wantKinds, "each problem should be reported")
+		})
+	}
+}
+
+func TestVerifyExpiredIntermediate(t *testing.T) {
+	pki := newTestPKI(t)
+	now := time.Now()
+
+	expired := newTestCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "spiry expired intermediate"},
+		NotBefore:             now.AddDate(-1, 0, 0),
+		NotAfter:              now.AddDate(0, 0, -1),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, &pki.root)
+
+	leaf := newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "localhost"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:   now.AddDate(0, 0, -7),
+		NotAfter:    now.AddDate(0, 0, 90),
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, &expired)
+
+	addr := serveTLS(t, &tls.Config{Certificates: []tls.Certificate{{
+		Certificate: [][]byte{leaf.cert.Raw, expired.cert.Raw},
+		PrivateKey:  leaf.key,
+	}}})
+
+	cert, _ := certificate.New(addr)
+	cert.Roots = x509.NewCertPool()
+	cert.Roots.AddCert(pki.root.cert)
+
+	v, err := cert.Verify()
+	assert.Nil(t, err, "a certificate should be retrieved")
+	assert.False(t, v.Verified, "a chain with an expired intermediate should fail verification")
+	assert.Equal(t, problemKinds(v), []string{certificate.ProblemExpired}, "the expired intermediate should be reported")
+	assert.Contains(t, v.Problems[0].Message, "spiry expired intermediate", "the expired certificate should be named")
+}
+
+func TestLoadCAFile(t *testing.T) {
+	pki := newTestPKI(t)
+	path := filepath.Join(t.TempDir(), "ca.pem")
+
+	err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: pki.root.cert.Raw}), 0o600)
+	assert.Nil(t, err)
+
+	pool, err := certificate.LoadCAFile(path)
+	assert.Nil(t, err, "a PEM CA file should be loaded")
+	assert.NotNil(t, pool, "a certificate pool should be returned")
+
+	err = os.WriteFile(path, []byte("not a certificate"), 0o600)
+	assert.Nil(t, err)
+
+	_, err = certificate.LoadCAFile(path)
+	assert.NotNil(t, err, "a CA file without certificates should raise an error")
+}
+
+func TestVerifyChasedIntermediate(t *testing.T) {
+	pki := newAIAPKI(t)
+
+	// only the leaf is presented
+	addr := serveTLS(t, &tls.Config{Certificates: []tls.Certificate{{
+		Certificate: [][]byte{pki.leaf.cert.Raw},
+		PrivateKey:  pki.leaf.key,
+	}}})
+
+	roots := x509.NewCertPool()
+	roots.AddCert(pki.root.cert)
+
+	cert, err := certificate.New(addr)
+	assert.Nil(t, err, "a host:port pair should parse")
+	cert.Roots = roots
+
+	v, err := cert.Verify()
+	assert.Nil(t, err, "the certificate should be retrieved")
+	assert.False(t, v.Verified, "a missing intermediate should fail verification")
+
+	cert, err = certificate.New(addr)
+	assert.Nil(t, err, "a host:port pair should parse")
+	cert.Roots = roots
+	cert.ChaseAIA = true
+
+	v, err = cert.Verify()
+	assert.Nil(t, err, "the certificate should be retrieved")
+	assert.True(t, v.Verified, "the intermediate fetched from AIA should complete the chain")
+}
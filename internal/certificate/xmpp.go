@@ -0,0 +1,87 @@
+package certificate
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// XML namespaces used when negotiating TLS with an XMPP server (RFC 6120)
+const (
+	xmppStreamNS = "http://etherx.jabber.org/streams"
+	xmppTLSNS    = "urn:ietf:params:xml:ns:xmpp-tls"
+)
+
+// startXMPPClient negotiates TLS on a client-to-server XMPP stream
+func startXMPPClient(conn net.Conn, name string) error {
+	return startXMPP(conn, name, "jabber:client")
+}
+
+// startXMPPServer negotiates TLS on a server-to-server XMPP stream
+func startXMPPServer(conn net.Conn, name string) error {
+	return startXMPP(conn, name, "jabber:server")
+}
+
+// startXMPP opens an XMPP stream, waits for the server to offer the
+// <starttls/> stream feature, and then asks to proceed with TLS
+func startXMPP(conn net.Conn, name string, namespace string) error {
+	_, err := fmt.Fprintf(conn, "<?xml version='1.0'?>"+
+		"<stream:stream to='%s' version='1.0' xmlns='%s' xmlns:stream='%s'>",
+		xmlEscape(name), namespace, xmppStreamNS)
+	if err != nil {
+		return err
+	}
+
+	decoder := xml.NewDecoder(conn)
+
+	// read the stream header and features, looking for <starttls/>
+	offered := false
+	features := false
+	for done := false; !done; {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		switch el := token.(type) {
+		case xml.StartElement:
+			if el.Name.Space == xmppStreamNS && el.Name.Local == "features" {
+				features = true
+			} else if features && el.Name.Space == xmppTLSNS && el.Name.Local == "starttls" {
+				offered = true
+			}
+		case xml.EndElement:
+			done = el.Name.Space == xmppStreamNS && el.Name.Local == "features"
+		}
+	}
+
+	if !offered {
+		return fmt.Errorf("XMPP server for %v does not offer STARTTLS", name)
+	}
+
+	if _, err := fmt.Fprintf(conn, "<starttls xmlns='%s'/>", xmppTLSNS); err != nil {
+		return err
+	}
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		if el, ok := token.(xml.StartElement); ok && el.Name.Space == xmppTLSNS {
+			if el.Name.Local != "proceed" {
+				return fmt.Errorf("XMPP server refused STARTTLS with <%s/>", el.Name.Local)
+			}
+			return nil
+		}
+	}
+}
+
+// xmlEscape escapes s for use in an XML attribute value
+func xmlEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
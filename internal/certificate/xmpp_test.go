@@ -0,0 +1,84 @@
+package certificate_test
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/likexian/gokit/assert"
+	"github.com/mckern/spiry/internal/certificate"
+)
+
+// xmppDialogue answers a client stream header with the given stream
+// features, and replies to <starttls/> with reply
+func xmppDialogue(features string, reply string) dialogue {
+	return func(conn net.Conn) error {
+		r := bufio.NewReader(conn)
+		header, err := r.ReadString('>')
+		for err == nil && !strings.Contains(header, "<stream:stream") {
+			header, err = r.ReadString('>')
+		}
+		if err != nil {
+			return err
+		}
+
+		_, _ = fmt.Fprintf(conn, "<?xml version='1.0'?>"+
+			"<stream:stream from='localhost' id='fake' version='1.0' "+
+			"xmlns='jabber:client' xmlns:stream='http://etherx.jabber.org/streams'>"+
+			"<stream:features>%s</stream:features>", features)
+
+		if _, err := r.ReadString('>'); err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprint(conn, reply)
+		return err
+	}
+}
+
+func TestXMPPStartTLS(t *testing.T) {
+	pki := newTestPKI(t)
+	config := &tls.Config{Certificates: []tls.Certificate{pki.tlsCertificate()}}
+
+	var xmppTests = []struct {
+		name     string
+		features string
+		reply    string
+		wantErr  bool
+	}{
+		{name: "STARTTLS offered and accepted",
+			features: "<starttls xmlns='urn:ietf:params:xml:ns:xmpp-tls'><required/></starttls>" +
+				"<mechanisms xmlns='urn:ietf:params:xml:ns:xmpp-sasl'><mechanism>PLAIN</mechanism></mechanisms>",
+			reply: "<proceed xmlns='urn:ietf:params:xml:ns:xmpp-tls'/>"},
+		{name: "STARTTLS offered and refused",
+			features: "<starttls xmlns='urn:ietf:params:xml:ns:xmpp-tls'/>",
+			reply:    "<failure xmlns='urn:ietf:params:xml:ns:xmpp-tls'/></stream:stream>",
+			wantErr:  true},
+		{name: "STARTTLS not offered",
+			features: "<mechanisms xmlns='urn:ietf:params:xml:ns:xmpp-sasl'><mechanism>PLAIN</mechanism></mechanisms>",
+			wantErr:  true},
+	}
+
+	for _, tt := range xmppTests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := serveStartTLS(t, config, xmppDialogue(tt.features, tt.reply))
+			_, port, _ := net.SplitHostPort(addr)
+
+			cert, err := certificate.New("xmpp://localhost:" + port)
+			assert.Nil(t, err, "an xmpp:// URL should parse")
+			assert.Equal(t, cert.StartTLS, "xmpp", "the URL scheme should select XMPP STARTTLS")
+
+			expiry, err := cert.Expiry()
+			if tt.wantErr {
+				assert.NotNil(t, err, "STARTTLS should fail")
+				return
+			}
+
+			assert.Nil(t, err, "a certificate should be retrieved after STARTTLS")
+			assert.True(t, expiry.Equal(pki.leaf.cert.NotAfter), "the leaf certificate should be retrieved")
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package crl
+
+import (
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/mckern/spiry/internal/certificate"
+	"github.com/mckern/spiry/internal/spiry"
+)
+
+// List is a certificate revocation list, read from a file or fetched
+// over HTTP. Its expiration date is its NextUpdate date, after which
+// relying parties stop trusting it.
+type List struct {
+	// Detailed includes the CRL's issuer, number, update
+	// date and number of revoked certificates in plain output
+	Detailed bool
+	// Network controls how a CRL at an HTTP(S) URL is fetched
+	Network certificate.NetworkOptions
+
+	location string
+	crl      *x509.RevocationList
+}
+
+var (
+	_ spiry.ExpiringResource = (*List)(nil)
+	_ spiry.Reporter         = (*List)(nil)
+)
+
+type Command struct {
+	Details bool `name:"details" short:"d" help:"display the CRL's issuer, number, update date and number of revoked certificates"`
+	certificate.NetworkFlags
+	Location string `arg:"" name:"location" help:"path or HTTP(S) URL of a DER or PEM encoded CRL"`
+}
+
+func (c *Command) Run(globals *spiry.Command) (err error) {
+	list := New(c.Location)
+	list.Detailed = c.Details
+	if u, ok := httpURL(c.Location); ok {
+		if list.Network, err = c.Options(u); err != nil {
+			return err
+		}
+	}
+
+	output, err := globals.Render(list)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(output)
+	return
+}
+
+func New(location string) *List {
+	return &List{location: location}
+}
+
+func (l *List) Name() string {
+	return l.location
+}
+
+// Expiry returns the CRL's NextUpdate date, reading the CRL on first use.
+func (l *List) Expiry() (time.Time, error) {
+	crl, err := l.RevocationList()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if crl.NextUpdate.IsZero() {
+		return time.Time{}, fmt.Errorf("CRL at %v has no next update date", l.location)
+	}
+
+	return crl.NextUpdate, nil
+}
+
+// RevocationList returns the parsed CRL, reading it on first use.
+func (l *List) RevocationList() (*x509.RevocationList, error) {
+	if l.crl != nil {
+		return l.crl, nil
+	}
+
+	data, err := l.read()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read CRL from %v: %w", l.location, err)
+	}
+
+	crl, err := certificate.ParseCRL(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read CRL from %v: %w", l.location, err)
+	}
+
+	l.crl = crl
+	return l.crl, nil
+}
+
+// Report describes the CRL's issuer, number, update dates and size,
+// which are only included in plain output if Detailed is set, and
+// satisfies spiry.Reporter.
+func (l *List) Report(format func(time.Time) string) (fields map[string]any, lines []string) {
+	fields = map[string]any{}
+	if l.crl == nil {
+		return
+	}
+
+	number := ""
+	if l.crl.Number != nil {
+		number = l.crl.Number.String()
+	}
+
+	fields["issuer"] = l.crl.Issuer.String()
+	fields["number"] = number
+	fields["thisUpdate"] = format(l.crl.ThisUpdate)
+	fields["revokedCertificates"] = len(l.crl.RevokedCertificateEntries)
+
+	if !l.Detailed {
+		return
+	}
+
+	line := func(label string, value string) {
+		if value != "" {
+			lines = append(lines, fmt.Sprintf("  %-20s %s", label+":", value))
+		}
+	}
+
+	line("issuer", l.crl.Issuer.String())
+	line("CRL number", number)
+	line("this update", format(l.crl.ThisUpdate))
+	line("revoked", fmt.Sprintf("%d certificates", len(l.crl.RevokedCertificateEntries)))
+
+	return
+}
+
+// read returns the contents of the file or HTTP(S) URL at the CRL's location
+func (l *List) read() ([]byte, error) {
+	u, ok := httpURL(l.location)
+	if !ok {
+		slog.Debug("reading CRL from file", "path", l.location)
+		return os.ReadFile(l.location)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.Network.Fetch(req)
+}
+
+// httpURL parses location as an HTTP(S) URL, reporting
+// whether it is one rather than the path of a file
+func httpURL(location string) (*url.URL, bool) {
+	u, err := url.Parse(location)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, false
+	}
+
+	return u, true
+}
@@ -0,0 +1,152 @@
+package crl_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mckern/spiry/internal/crl"
+	"github.com/stretchr/testify/assert"
+)
+
+// newCRL returns a DER encoded CRL, issued by a throwaway CA,
+// revoking one certificate and due for update after lifetime
+func newCRL(t *testing.T, lifetime time.Duration) (der []byte, nextUpdate time.Time) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "spiry test CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	caDER, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nextUpdate = now.Add(lifetime)
+	der, err = x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(42),
+		ThisUpdate: now.Add(-time.Hour),
+		NextUpdate: nextUpdate,
+		RevokedCertificateEntries: []x509.RevocationListEntry{
+			{SerialNumber: big.NewInt(1234), RevocationTime: now.Add(-time.Hour)},
+		},
+	}, ca, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return der, nextUpdate
+}
+
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestDER(t *testing.T) {
+	der, nextUpdate := newCRL(t, 7*24*time.Hour)
+	list := crl.New(writeFile(t, "ca.crl", der))
+
+	expiry, err := list.Expiry()
+	assert.NoError(t, err)
+	assert.Equal(t, nextUpdate, expiry, "the CRL's next update should be its expiry")
+}
+
+func TestPEM(t *testing.T) {
+	der, nextUpdate := newCRL(t, 7*24*time.Hour)
+	list := crl.New(writeFile(t, "ca.pem", pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der})))
+
+	expiry, err := list.Expiry()
+	assert.NoError(t, err)
+	assert.Equal(t, nextUpdate, expiry, "the CRL's next update should be its expiry")
+}
+
+func TestURL(t *testing.T) {
+	der, nextUpdate := newCRL(t, 12*time.Hour)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/pkix-crl")
+		_, _ = w.Write(der)
+	}))
+	defer server.Close()
+
+	list := crl.New(server.URL + "/ca.crl")
+	expiry, err := list.Expiry()
+	assert.NoError(t, err)
+	assert.Equal(t, nextUpdate, expiry, "the CRL's next update should be its expiry")
+
+	format := func(t time.Time) string { return t.Format(time.RFC3339) }
+	fields, lines := list.Report(format)
+	assert.Equal(t, "42", fields["number"])
+	assert.Equal(t, 1, fields["revokedCertificates"])
+	assert.Empty(t, lines, "details should only be reported when asked for")
+
+	list.Detailed = true
+	_, lines = list.Report(format)
+	assert.Len(t, lines, 4)
+}
+
+func TestURLThroughProxy(t *testing.T) {
+	der, nextUpdate := newCRL(t, 12*time.Hour)
+	requested := ""
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.String()
+		_, _ = w.Write(der)
+	}))
+	defer proxy.Close()
+
+	list := crl.New("http://crl.example.invalid/ca.crl")
+	list.Network.Proxy, _ = url.Parse(proxy.URL)
+
+	expiry, err := list.Expiry()
+	assert.NoError(t, err, "the CRL should be fetched through the proxy")
+	assert.Equal(t, nextUpdate, expiry)
+	assert.Equal(t, "http://crl.example.invalid/ca.crl", requested, "the proxy should be asked for the CRL")
+}
+
+func TestURLNotFound(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	_, err := crl.New(server.URL + "/ca.crl").Expiry()
+	assert.Error(t, err)
+}
+
+func TestInvalid(t *testing.T) {
+	_, err := crl.New(writeFile(t, "ca.crl", []byte("not a CRL"))).Expiry()
+	assert.Error(t, err)
+}
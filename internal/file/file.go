@@ -0,0 +1,164 @@
+package file
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/mckern/spiry/internal/certificate"
+	"github.com/mckern/spiry/internal/spiry"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// Bundle is a file on disk containing one or more certificates,
+// encoded as PEM, DER or PKCS#12. Its expiration date is the
+// earliest expiration date of any certificate it contains.
+type Bundle struct {
+	// Password decrypts PKCS#12 archives
+	Password string
+
+	path  string
+	certs []*x509.Certificate
+}
+
+var (
+	_ spiry.ExpiringResource = (*Bundle)(nil)
+	_ spiry.Reporter         = (*Bundle)(nil)
+)
+
+type Command struct {
+	Password string `name:"password" short:"p" env:"SPIRY_PKCS12_PASSWORD" help:"decrypt PKCS#12 archives with <password>"`
+	Path     string `arg:"" name:"path" type:"existingfile" help:"PEM, DER or PKCS#12 file to read certificates from"`
+}
+
+func (c *Command) Run(globals *spiry.Command) (err error) {
+	bundle := New(c.Path)
+	bundle.Password = c.Password
+
+	output, err := globals.Render(bundle)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(output)
+	return
+}
+
+func New(path string) *Bundle {
+	return &Bundle{path: path}
+}
+
+func (b *Bundle) Name() string {
+	return b.path
+}
+
+// Expiry returns the earliest expiration date of the
+// certificates in the bundle, reading it on first use.
+func (b *Bundle) Expiry() (time.Time, error) {
+	certs, err := b.Certificates()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return certificate.EarliestExpiry(certs), nil
+}
+
+// Certificates returns every certificate in the bundle, in the order
+// they appear in the file, reading them on first use.
+func (b *Bundle) Certificates() ([]*x509.Certificate, error) {
+	if b.certs != nil {
+		return b.certs, nil
+	}
+
+	data, err := os.ReadFile(b.path)
+	if err != nil {
+		return nil, err
+	}
+
+	certs, err := Parse(data, b.Password)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read certificates from %v: %w", b.path, err)
+	}
+
+	b.certs = certs
+	return b.certs, nil
+}
+
+// Report describes every certificate in the bundle,
+// and satisfies spiry.Reporter.
+func (b *Bundle) Report(format func(time.Time) string) (fields map[string]any, lines []string) {
+	fields = map[string]any{}
+	fields["certificates"], lines = certificate.DescribeChain(b.certs, format)
+	return
+}
+
+// Parse returns every certificate found in data, which may be a PEM
+// bundle, one or more concatenated DER certificates, or a PKCS#12
+// archive decrypted with password.
+func Parse(data []byte, password string) ([]*x509.Certificate, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		slog.Debug("parsing certificates as PEM")
+		return parsePEM(data)
+	}
+
+	slog.Debug("parsing certificates as DER")
+	if certs, err := x509.ParseCertificates(data); err == nil && len(certs) > 0 {
+		return certs, nil
+	}
+
+	slog.Debug("parsing certificates as PKCS#12")
+	return parsePKCS12(data, password)
+}
+
+// parsePEM returns every certificate in a PEM bundle,
+// skipping over any keys or other blocks
+func parsePEM(data []byte) (certs []*x509.Certificate, err error) {
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			slog.Debug("skipping PEM block", "type", block.Type)
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New("no certificates found in PEM data")
+	}
+
+	return certs, nil
+}
+
+// parsePKCS12 returns the certificate and CA certificates of a PKCS#12
+// archive with a private key, or the certificates of a trust store
+func parsePKCS12(data []byte, password string) ([]*x509.Certificate, error) {
+	_, cert, caCerts, err := pkcs12.DecodeChain(data, password)
+	if err == nil {
+		return append([]*x509.Certificate{cert}, caCerts...), nil
+	}
+
+	if errors.Is(err, pkcs12.ErrIncorrectPassword) {
+		return nil, err
+	}
+
+	certs, trustErr := pkcs12.DecodeTrustStore(data, password)
+	if errors.Is(trustErr, pkcs12.ErrIncorrectPassword) {
+		return nil, trustErr
+	} else if trustErr != nil {
+		return nil, fmt.Errorf("data is not PEM, DER or PKCS#12: %w", err)
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New("no certificates found in PKCS#12 archive")
+	}
+
+	return certs, nil
+}
@@ -0,0 +1,138 @@
+package file_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mckern/spiry/internal/file"
+	"github.com/stretchr/testify/assert"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// newCert issues a self-signed certificate that expires after lifetime
+func newCert(t *testing.T, name string, lifetime time.Duration) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().Truncate(time.Second)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(now.UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(lifetime),
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert, key
+}
+
+// writeFile writes data to a file named name in a temporary directory
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestBundleFormats(t *testing.T) {
+	leaf, key := newCert(t, "leaf", 90*24*time.Hour)
+	ca, _ := newCert(t, "ca", 30*24*time.Hour)
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.Nil(t, err)
+
+	var bundle []byte
+	bundle = append(bundle, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})...)
+	bundle = append(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw})...)
+	bundle = append(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})...)
+
+	archive, err := pkcs12.Modern.Encode(key, leaf, []*x509.Certificate{ca}, "hunter2")
+	assert.Nil(t, err)
+
+	trustStore, err := pkcs12.Modern.EncodeTrustStore([]*x509.Certificate{leaf, ca}, "hunter2")
+	assert.Nil(t, err)
+
+	var formatTests = []struct {
+		name      string
+		data      []byte
+		password  string
+		wantCount int
+		wantErr   bool
+	}{
+		{name: "a PEM bundle with a private key", data: bundle, wantCount: 2},
+		{name: "a single DER certificate", data: leaf.Raw, wantCount: 1},
+		{name: "concatenated DER certificates", data: append(append([]byte{}, leaf.Raw...), ca.Raw...), wantCount: 2},
+		{name: "a PKCS#12 archive", data: archive, password: "hunter2", wantCount: 2},
+		{name: "a PKCS#12 trust store", data: trustStore, password: "hunter2", wantCount: 2},
+		{name: "a PKCS#12 archive with the wrong password", data: archive, password: "hunter3", wantErr: true},
+		{name: "a PEM file without certificates",
+			data:    pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}),
+			wantErr: true},
+		{name: "a file that isn't a certificate at all", data: []byte("hello, world\n"), wantErr: true},
+	}
+
+	for _, tt := range formatTests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := file.New(writeFile(t, "certs", tt.data))
+			b.Password = tt.password
+
+			expiry, err := b.Expiry()
+			if tt.wantErr {
+				assert.NotNil(t, err, "an unreadable file should raise an error")
+				return
+			}
+
+			assert.Nil(t, err, "certificates should be read from the file")
+
+			certs, _ := b.Certificates()
+			assert.Len(t, certs, tt.wantCount, "every certificate in the file should be read")
+
+			want := leaf.NotAfter
+			if tt.wantCount > 1 {
+				want = ca.NotAfter
+			}
+			assert.True(t, expiry.Equal(want), "the earliest expiration date should be used")
+		})
+	}
+}
+
+func TestBundleReport(t *testing.T) {
+	leaf, _ := newCert(t, "leaf", 90*24*time.Hour)
+	ca, _ := newCert(t, "ca", 30*24*time.Hour)
+
+	b := file.New(writeFile(t, "certs.der", append(append([]byte{}, leaf.Raw...), ca.Raw...)))
+	_, err := b.Expiry()
+	assert.Nil(t, err)
+
+	fields, lines := b.Report(func(t time.Time) string { return t.Format(time.RFC3339) })
+	assert.Len(t, lines, 2, "every certificate should be reported")
+	assert.Contains(t, fields, "certificates", "certificates should be reported as a JSON field")
+}
@@ -0,0 +1,9 @@
+package lint
+
+// Unregister removes the lint named name, undoing Register
+func Unregister(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	delete(registry, name)
+}
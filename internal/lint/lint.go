@@ -0,0 +1,124 @@
+// Package lint checks certificates for problems that are likely to cause
+// trouble before they expire. Lints are registered by name, and further
+// lints can be added with Register.
+package lint
+
+import (
+	"cmp"
+	"crypto/x509"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// severities of findings
+const (
+	// SeverityError findings break clients, or will soon
+	SeverityError = "error"
+	// SeverityWarning findings are worth knowing about,
+	// but are not a policy failure
+	SeverityWarning = "warning"
+)
+
+// Context is what a lint may need to know about a
+// certificate beyond the certificate itself
+type Context struct {
+	// Now is the time the certificate is checked at
+	Now time.Time
+	// OCSPStapled is set when the server stapled an OCSP response
+	// to the handshake the certificate was retrieved in
+	OCSPStapled bool
+}
+
+// Lint is a single check of a certificate
+type Lint struct {
+	Name        string
+	Description string
+	Severity    string
+	// Check returns a description of the problem found with cert,
+	// or "" if there is none
+	Check func(cert *x509.Certificate, ctx Context) string
+}
+
+// Finding is a problem found by a lint
+type Finding struct {
+	Lint     string `json:"lint"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+var (
+	mu       sync.RWMutex
+	registry = map[string]Lint{}
+)
+
+// Register adds l to the lints run by Registered. Registering
+// a lint with the same name as another replaces it.
+func Register(l Lint) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	registry[l.Name] = l
+}
+
+// Registered returns every registered lint, sorted by name
+func Registered() []Lint {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	lints := make([]Lint, 0, len(registry))
+	for _, l := range registry {
+		lints = append(lints, l)
+	}
+
+	slices.SortFunc(lints, func(a, b Lint) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	return lints
+}
+
+// Names returns the name of every registered lint, sorted
+func Names() []string {
+	lints := Registered()
+	names := make([]string, 0, len(lints))
+	for _, l := range lints {
+		names = append(names, l.Name)
+	}
+
+	return names
+}
+
+// Run checks cert with every lint in lints, skipping any named in skip
+func Run(cert *x509.Certificate, ctx Context, lints []Lint, skip ...string) []Finding {
+	findings := []Finding{}
+	for _, l := range lints {
+		if slices.Contains(skip, l.Name) {
+			continue
+		}
+
+		if message := l.Check(cert, ctx); message != "" {
+			findings = append(findings, Finding{Lint: l.Name, Severity: l.Severity, Message: message})
+		}
+	}
+
+	return findings
+}
+
+// Failed returns the names of the lints that produced
+// error findings, in the order they were found
+func Failed(findings []Finding) (names []string) {
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			names = append(names, f.Lint)
+		}
+	}
+
+	return
+}
+
+// String describes f in a line of plain output
+func (f Finding) String() string {
+	return fmt.Sprintf("%s (%s): %s", f.Severity, f.Lint, f.Message)
+}
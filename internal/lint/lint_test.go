@@ -0,0 +1,169 @@
+package lint_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/mckern/spiry/internal/lint"
+	"github.com/stretchr/testify/assert"
+)
+
+// newCert issues a self-signed certificate from template, using key if
+// one is given and a fresh ECDSA key otherwise
+func newCert(t *testing.T, template *x509.Certificate, key crypto.Signer) *x509.Certificate {
+	t.Helper()
+
+	if key == nil {
+		var err error
+		if key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	template.SerialNumber = big.NewInt(1)
+	template.Subject = pkix.Name{CommonName: "example.com"}
+	template.DNSNames = []string{"example.com"}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert
+}
+
+func lints(findings []lint.Finding) (names []string) {
+	for _, f := range findings {
+		names = append(names, f.Lint)
+	}
+
+	return
+}
+
+func TestRun(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	serverAuth := []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+
+	smallKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mustStaple, err := asn1.Marshal([]int{5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		template *x509.Certificate
+		key      crypto.Signer
+		ctx      lint.Context
+		want     []string
+		// warning is set when the findings are advisory,
+		// and shouldn't fail the check
+		warning bool
+	}{
+		{
+			name:     "clean",
+			template: &x509.Certificate{NotBefore: now.Add(-time.Hour), NotAfter: now.AddDate(0, 0, 90), ExtKeyUsage: serverAuth},
+		},
+		{
+			name:     "lifetime",
+			template: &x509.Certificate{NotBefore: now.Add(-time.Hour), NotAfter: now.AddDate(2, 0, 0), ExtKeyUsage: serverAuth},
+			want:     []string{"lifetime-too-long"},
+			warning:  true,
+		},
+		{
+			name:     "398 days",
+			template: &x509.Certificate{NotBefore: now, NotAfter: now.AddDate(0, 0, 398).Add(-time.Second), ExtKeyUsage: serverAuth},
+		},
+		{
+			name:     "sha1 and small key",
+			template: &x509.Certificate{NotBefore: now.Add(-time.Hour), NotAfter: now.AddDate(0, 0, 90), ExtKeyUsage: serverAuth, SignatureAlgorithm: x509.SHA1WithRSA},
+			key:      smallKey,
+			want:     []string{"rsa-key-too-small", "sha1-signature"},
+		},
+		{
+			name:     "client auth only",
+			template: &x509.Certificate{NotBefore: now.Add(-time.Hour), NotAfter: now.AddDate(0, 0, 90), ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}},
+			want:     []string{"missing-server-auth"},
+		},
+		{
+			name:     "clock skew",
+			template: &x509.Certificate{NotBefore: now.Add(time.Hour), NotAfter: now.AddDate(0, 0, 90), ExtKeyUsage: serverAuth},
+			want:     []string{"not-yet-valid"},
+		},
+		{
+			name: "must-staple",
+			template: &x509.Certificate{NotBefore: now.Add(-time.Hour), NotAfter: now.AddDate(0, 0, 90), ExtKeyUsage: serverAuth,
+				ExtraExtensions: []pkix.Extension{{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 24}, Value: mustStaple}}},
+			want: []string{"must-staple-not-stapled"},
+		},
+		{
+			name: "must-staple stapled",
+			template: &x509.Certificate{NotBefore: now.Add(-time.Hour), NotAfter: now.AddDate(0, 0, 90), ExtKeyUsage: serverAuth,
+				ExtraExtensions: []pkix.Extension{{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 24}, Value: mustStaple}}},
+			ctx: lint.Context{OCSPStapled: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert := newCert(t, tt.template, tt.key)
+
+			ctx := tt.ctx
+			ctx.Now = now
+			findings := lint.Run(cert, ctx, lint.Registered())
+			assert.Equal(t, tt.want, lints(findings))
+			if tt.warning {
+				assert.Empty(t, lint.Failed(findings), "warnings should not fail")
+			} else {
+				assert.Equal(t, tt.want, lint.Failed(findings))
+			}
+		})
+	}
+}
+
+func TestRunSkip(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	cert := newCert(t, &x509.Certificate{NotBefore: now.Add(-time.Hour), NotAfter: now.AddDate(2, 0, 0)}, nil)
+
+	findings := lint.Run(cert, lint.Context{Now: now}, lint.Registered(), "lifetime-too-long")
+	assert.Equal(t, []string{"missing-server-auth"}, lints(findings))
+}
+
+func TestRegister(t *testing.T) {
+	lint.Register(lint.Lint{
+		Name:     "zz-test-warning",
+		Severity: lint.SeverityWarning,
+		Check: func(cert *x509.Certificate, _ lint.Context) string {
+			return "always found"
+		},
+	})
+	t.Cleanup(func() { lint.Unregister("zz-test-warning") })
+
+	assert.Contains(t, lint.Names(), "zz-test-warning")
+
+	now := time.Now().Truncate(time.Second)
+	cert := newCert(t, &x509.Certificate{NotBefore: now.Add(-time.Hour), NotAfter: now.AddDate(0, 0, 90), ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}}, nil)
+
+	findings := lint.Run(cert, lint.Context{Now: now}, lint.Registered())
+	assert.Equal(t, []string{"zz-test-warning"}, lints(findings))
+	assert.Empty(t, lint.Failed(findings), "warnings should not fail")
+	assert.Equal(t, "warning (zz-test-warning): always found", findings[0].String())
+}
@@ -0,0 +1,140 @@
+package lint
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// limits set by the CA/Browser Forum Baseline Requirements
+const (
+	maxLifetimeDays = 398
+	minRSAKeySize   = 2048
+)
+
+// oidTLSFeature identifies the TLS feature extension of RFC 7633,
+// and tlsFeatureStatusRequest the feature that makes it "must-staple"
+var (
+	oidTLSFeature           = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 24}
+	tlsFeatureStatusRequest = 5
+)
+
+func init() {
+	Register(Lint{
+		Name:        "lifetime-too-long",
+		Description: fmt.Sprintf("the certificate is valid for more than %d days", maxLifetimeDays),
+		Severity:    SeverityWarning,
+		Check:       checkLifetime,
+	})
+
+	Register(Lint{
+		Name:        "sha1-signature",
+		Description: "the certificate is signed with SHA-1",
+		Severity:    SeverityError,
+		Check:       checkSHA1,
+	})
+
+	Register(Lint{
+		Name:        "rsa-key-too-small",
+		Description: fmt.Sprintf("the certificate has an RSA key smaller than %d bits", minRSAKeySize),
+		Severity:    SeverityError,
+		Check:       checkRSAKeySize,
+	})
+
+	Register(Lint{
+		Name:        "missing-server-auth",
+		Description: "the certificate lacks the serverAuth extended key usage",
+		Severity:    SeverityError,
+		Check:       checkServerAuth,
+	})
+
+	Register(Lint{
+		Name:        "not-yet-valid",
+		Description: "the certificate's NotBefore date is in the future",
+		Severity:    SeverityError,
+		Check:       checkNotBefore,
+	})
+
+	Register(Lint{
+		Name:        "must-staple-not-stapled",
+		Description: "the certificate requires OCSP stapling, but no response was stapled",
+		Severity:    SeverityError,
+		Check:       checkMustStaple,
+	})
+}
+
+func checkLifetime(cert *x509.Certificate, _ Context) string {
+	// validity periods are inclusive of both NotBefore and NotAfter
+	lifetime := cert.NotAfter.Sub(cert.NotBefore) + time.Second
+	if lifetime > maxLifetimeDays*24*time.Hour {
+		return fmt.Sprintf("valid for %.0f days, more than the %d clients accept",
+			lifetime.Hours()/24, maxLifetimeDays)
+	}
+
+	return ""
+}
+
+func checkSHA1(cert *x509.Certificate, _ Context) string {
+	switch cert.SignatureAlgorithm {
+	case x509.SHA1WithRSA, x509.ECDSAWithSHA1, x509.DSAWithSHA1:
+		return fmt.Sprintf("signed with %s, which clients reject", cert.SignatureAlgorithm)
+	}
+
+	return ""
+}
+
+func checkRSAKeySize(cert *x509.Certificate, _ Context) string {
+	if key, ok := cert.PublicKey.(*rsa.PublicKey); ok && key.N.BitLen() < minRSAKeySize {
+		return fmt.Sprintf("RSA key is %d bits, less than %d", key.N.BitLen(), minRSAKeySize)
+	}
+
+	return ""
+}
+
+func checkServerAuth(cert *x509.Certificate, _ Context) string {
+	if slices.Contains(cert.ExtKeyUsage, x509.ExtKeyUsageServerAuth) ||
+		slices.Contains(cert.ExtKeyUsage, x509.ExtKeyUsageAny) {
+		return ""
+	}
+
+	return "no serverAuth extended key usage, so it cannot be used by TLS servers"
+}
+
+func checkNotBefore(cert *x509.Certificate, ctx Context) string {
+	if cert.NotBefore.After(ctx.Now) {
+		return fmt.Sprintf("not valid until %s, %s from now; check for clock skew",
+			cert.NotBefore.Format(time.RFC3339), cert.NotBefore.Sub(ctx.Now).Round(time.Second))
+	}
+
+	return ""
+}
+
+func checkMustStaple(cert *x509.Certificate, ctx Context) string {
+	if mustStaple(cert) && !ctx.OCSPStapled {
+		return "must-staple is set, but the server stapled no OCSP response"
+	}
+
+	return ""
+}
+
+// mustStaple reports whether cert has a TLS feature
+// extension requiring the status_request feature
+func mustStaple(cert *x509.Certificate) bool {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidTLSFeature) {
+			continue
+		}
+
+		var features []int
+		if _, err := asn1.Unmarshal(ext.Value, &features); err != nil {
+			return false
+		}
+
+		return slices.Contains(features, tlsFeatureStatusRequest)
+	}
+
+	return false
+}
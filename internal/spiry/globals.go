@@ -3,6 +3,7 @@ package spiry
 import (
 	"encoding/json"
 	"fmt"
+	"maps"
 	"strconv"
 	"time"
 
@@ -27,34 +28,50 @@ type Command struct {
 	Time         string `kong:"-"`
 }
 
+// FormatTime formats t according to the time formatting flags
+// selected on the command line, defaulting to ISO8601.
+func (g *Command) FormatTime(t time.Time) string {
+	if g.UnixFlag {
+		return strconv.FormatInt(t.Unix(), 10)
+	} else if g.Rfc1123zFlag {
+		return t.Format(time.RFC1123Z)
+	} else if g.Rfc3339Flag {
+		return t.Format(time.RFC3339)
+	}
+
+	return t.Format(ISO8601)
+}
+
 func (g *Command) Render(res ExpiringResource) (output string, err error) {
 	expiry, err := res.Expiry()
 	if err != nil {
 		return output, err
 	}
 
-	// define a default time format
-	timeFmt := expiry.Format(ISO8601)
-	if g.UnixFlag {
-		timeFmt = strconv.FormatInt(expiry.Unix(), 10)
-	} else if g.Rfc1123zFlag {
-		timeFmt = expiry.Format(time.RFC1123Z)
-	} else if g.Rfc3339Flag {
-		timeFmt = expiry.Format(time.RFC3339)
+	timeFmt := g.FormatTime(expiry)
+
+	// some resources have more to say than a name and an expiration date
+	var fields map[string]any
+	var lines []string
+	if reporter, ok := res.(Reporter); ok {
+		fields, lines = reporter.Report(g.FormatTime)
 	}
 
 	// define a default output formatting
 	output = fmt.Sprintf("%s\t%s", res.Name(), timeFmt)
+	for _, line := range lines {
+		output += "\n" + line
+	}
 
 	// redefine output formatting if a user requested
 	// something besides the default values
 	if g.BareFlag {
 		output = timeFmt
 	} else if g.JsonFlag {
-		jsonStruct := map[string]string{
-			"domainName": res.Name(),
-			"expiry":     timeFmt,
-		}
+		jsonStruct := map[string]any{}
+		maps.Copy(jsonStruct, fields)
+		jsonStruct["domainName"] = res.Name()
+		jsonStruct["expiry"] = timeFmt
 
 		jsonOut, err := json.MarshalIndent(jsonStruct, "", "  ")
 		if err != nil {
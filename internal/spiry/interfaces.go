@@ -6,3 +6,11 @@ type ExpiringResource interface {
 	Name() string
 	Expiry() (time.Time, error)
 }
+
+// Reporter is implemented by resources that have more to say than a
+// name and an expiration date. Report is handed the time format selected
+// on the command line, and returns any additional fields for JSON output
+// along with any additional lines for plain output.
+type Reporter interface {
+	Report(format func(time.Time) string) (fields map[string]any, lines []string)
+}